@@ -0,0 +1,58 @@
+// Scripts of the memo pages, served from /script.js with the other
+// scripts in this directory.
+
+(function () {
+  // Reload when memos change on disk, unless something is being typed.
+  // A page whose memo is gone falls back to the index. Exported pages
+  // have no server to listen to.
+  var live = !document.body.hasAttribute("data-static");
+  if (live && window.EventSource && !document.querySelector(".memo-editor")) {
+    new EventSource("/events").addEventListener("reload", function () {
+      fetch(location.href, {method: "HEAD"}).then(function (res) {
+        if (res.status === 404) {
+          location.href = "/";
+        } else {
+          location.reload();
+        }
+      });
+    });
+  }
+
+  // Forms that can't be undone, like deleting a memo, ask first
+  document.querySelectorAll("form[data-confirm]").forEach(function (form) {
+    form.addEventListener("submit", function (event) {
+      if (!confirm(form.dataset.confirm)) {
+        event.preventDefault();
+      }
+    });
+  });
+
+  // Tasks are ticked off on the memo page when it can be changed
+  var taskForm = document.querySelector(".task-form");
+  if (taskForm) {
+    document.querySelectorAll("input.task[data-line]").forEach(function (box) {
+      box.disabled = false;
+      box.addEventListener("change", function () {
+        taskForm.elements.line.value = box.dataset.line;
+        taskForm.submit();
+      });
+    });
+  }
+
+  // The editor keeps the preview next to the textarea up to date
+  var editor = document.querySelector(".memo-editor");
+  if (editor) {
+    var preview = editor.querySelector(".editor-preview");
+    var timer;
+    var refresh = function () {
+      fetch("/preview", {method: "POST", body: new URLSearchParams(new FormData(editor))})
+        .then(function (res) { return res.text(); })
+        .then(function (html) { preview.innerHTML = html; });
+    };
+    editor.addEventListener("input", function () {
+      clearTimeout(timer);
+      timer = setTimeout(refresh, 300);
+    });
+    refresh();
+  }
+})();
@@ -0,0 +1,443 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The JSON API lives under this prefix, see addAPIRoutes
+const apiPrefix = "/api/v1"
+
+// Paging defaults of the memo list
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 500
+)
+
+// memoJSON is a memo as the API returns it, Raw and HTML are only set
+// when a single memo is asked for.
+type memoJSON struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Path        string    `json:"path"`
+	Folder      string    `json:"folder"`
+	Format      string    `json:"format"`
+	Tags        []string  `json:"tags"`
+	Created     string    `json:"created,omitempty"`
+	Modified    time.Time `json:"modified"`
+	URL         string    `json:"url"`
+	Attachments []string  `json:"attachments,omitempty"`
+	Raw         string    `json:"raw,omitempty"`
+	HTML        string    `json:"html,omitempty"`
+}
+
+type memoListJSON struct {
+	Memos  []memoJSON `json:"memos"`
+	Total  int        `json:"total"`
+	Offset int        `json:"offset"`
+	Limit  int        `json:"limit"`
+}
+
+// memoInput is the body of create and update requests
+// memoInput is the body of a POST or a PUT, Content is nil when it
+// wasn't sent
+type memoInput struct {
+	Title   string  `json:"title"`
+	Content *string `json:"content"`
+	Folder  string  `json:"folder"`
+	Format  string  `json:"format"`
+}
+
+type searchJSON struct {
+	Memo  memoJSON     `json:"memo"`
+	Lines []searchLine `json:"lines"`
+}
+
+func addAPIRoutes(mux *http.ServeMux) {
+	mux.HandleFunc(apiPrefix+"/memos", apiMemos)
+	mux.HandleFunc(apiPrefix+"/memos/", apiMemo)
+	mux.HandleFunc(apiPrefix+"/search", apiSearch)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		log.Print(err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// toMemoJSON describes memo, full adds the content and the rendered HTML
+func toMemoJSON(memo memoFile, full bool) (memoJSON, error) {
+	content, err := os.ReadFile(memo.Path)
+	if err != nil {
+		return memoJSON{}, err
+	}
+	info, err := os.Stat(memo.Path)
+	if err != nil {
+		return memoJSON{}, err
+	}
+
+	rel, _ := filepath.Rel(getKeyValue("MemoDir").(string), memo.Path)
+	m := memoJSON{
+		ID:       memo.Number,
+		Title:    memoTitle(memo.Path),
+		Path:     filepath.ToSlash(rel),
+		Folder:   memo.Folder,
+		Format:   formatOf(memo.Path).Name,
+		Tags:     memoTags(string(content)),
+		Modified: info.ModTime(),
+		URL:      memoURL(memo),
+	}
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+	if !memo.Date.IsZero() {
+		m.Created = memo.Date.Format("2006-01-02")
+	}
+
+	if full {
+		m.Raw = string(content)
+		md := attachmentLinks(memoMarkdown(memo.Path, content), memo.Path, fmt.Sprintf("/attachments/%d/", memo.Number))
+		m.HTML = string(mdToHTML(md))
+		m.Attachments = listAttachments(memo.Path)
+	}
+
+	return m, nil
+}
+
+// apiMemos handles /api/v1/memos
+//
+//	GET  lists the memos, filtered by ?folder= ?tag= ?q= and paged
+//	     with ?offset= and ?limit=, ?sort=number|created|modified|title, - reverses it
+//	POST creates a memo from {"title", "content", "folder", "format"}
+func apiMemos(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		apiListMemos(w, r)
+	case http.MethodPost:
+		apiCreateMemo(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "%s is not allowed here", r.Method)
+	}
+}
+
+func apiListMemos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	memoDir := getKeyValue("MemoDir").(string)
+
+	folder, err := cleanFolder(query.Get("folder"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	offset, limit, err := paging(query.Get("offset"), query.Get("limit"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	memos := readMemos(memoDir)
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		memos = nil
+		for _, result := range searchMemos(memoDir, q) {
+			memos = append(memos, result.Memo)
+		}
+	}
+
+	tag := strings.ToLower(query.Get("tag"))
+	var list []memoJSON
+	for _, memo := range memos {
+		if !inFolder(memo, folder) {
+			continue
+		}
+		m, err := toMemoJSON(memo, false)
+		if err != nil {
+			continue
+		}
+		if tag != "" && !containsString(m.Tags, tag) {
+			continue
+		}
+		list = append(list, m)
+	}
+
+	if err := sortMemoJSON(list, query.Get("sort")); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	page := memoListJSON{Memos: []memoJSON{}, Total: len(list), Offset: offset, Limit: limit}
+	if offset < len(list) {
+		page.Memos = list[offset:min(offset+limit, len(list))]
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func paging(offsetParam string, limitParam string) (int, int, error) {
+	offset, limit := 0, apiDefaultLimit
+
+	if offsetParam != "" {
+		o, err := strconv.Atoi(offsetParam)
+		if err != nil || o < 0 {
+			return 0, 0, fmt.Errorf("offset has to be a positive number")
+		}
+		offset = o
+	}
+	if limitParam != "" {
+		l, err := strconv.Atoi(limitParam)
+		if err != nil || l < 1 {
+			return 0, 0, fmt.Errorf("limit has to be a number above 0")
+		}
+		limit = min(l, apiMaxLimit)
+	}
+
+	return offset, limit, nil
+}
+
+func sortMemoJSON(list []memoJSON, order string) error {
+	if order == "" {
+		order = "number"
+	}
+	key := func(m memoJSON) memoSortKey {
+		created, _ := time.Parse(time.DateOnly, m.Created)
+		return memoSortKey{Number: m.ID, Created: created, Modified: m.Modified, Title: m.Title}
+	}
+	if !sortMemos(list, order, key) {
+		return fmt.Errorf("can't sort by %q, use number, created, modified or title", order)
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// memoWrites lets memo serve change one memo at a time, from picking the
+// number of a new memo to committing it. Without it two new memos get
+// the same number and go-git commits over each other.
+var memoWrites sync.Mutex
+
+// apiWriteAllowed refuses the writes a web page could send behind the
+// back of its visitor. Bodies have to be JSON, browsers only send that
+// to another site after a preflight memo serve never answers, and a
+// request with an Origin has to come from memo serve itself.
+func apiWriteAllowed(w http.ResponseWriter, r *http.Request, body bool) bool {
+	if r.Header.Get("Origin") != "" && !sameOrigin(r) {
+		writeJSONError(w, http.StatusForbidden, "requests from other sites aren't allowed")
+		return false
+	}
+	if !body {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "send the body as application/json")
+		return false
+	}
+
+	return true
+}
+
+func apiCreateMemo(w http.ResponseWriter, r *http.Request) {
+	if !apiWriteAllowed(w, r, true) {
+		return
+	}
+
+	var input memoInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+
+	input.Title = strings.TrimSpace(input.Title)
+	if input.Title == "" {
+		writeJSONError(w, http.StatusBadRequest, "a memo needs a title")
+		return
+	}
+	folder, err := cleanFolder(input.Folder)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if input.Format == "" {
+		input.Format = getKeyValue("Format").(string)
+	}
+	format, err := findFormat(input.Format)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	content := ""
+	if input.Content != nil {
+		content = *input.Content
+	}
+
+	memoWrites.Lock()
+	filename, err := saveNewMemo(input.Title, folder, format, content)
+	memoWrites.Unlock()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "couldn't create the memo: %v", err)
+		return
+	}
+
+	memo, ok := memoByPath(filename)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "the memo was saved in %s but can't be found again, check the filenames setting", filename)
+		return
+	}
+	m, err := toMemoJSON(memo, true)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/memos/%d", apiPrefix, memo.Number))
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// apiMemo handles /api/v1/memos/<number>
+//
+//	GET    the memo with its raw content and rendered HTML
+//	PUT    replaces the content with {"content"}, nothing else
+//	DELETE removes the memo and its attachments
+func apiMemo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix+"/memos/"), "/"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "no such memo")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		memo, ok := memoByNumber(getKeyValue("MemoDir").(string), id)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "there is no memo %d", id)
+			return
+		}
+		m, err := toMemoJSON(memo, true)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m)
+	case http.MethodPut:
+		if !apiWriteAllowed(w, r, true) {
+			return
+		}
+		var input memoInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+			return
+		}
+		if input.Title != "" || input.Folder != "" || input.Format != "" {
+			writeJSONError(w, http.StatusBadRequest, "only the content of a memo can be changed, the title is in the content")
+			return
+		}
+		if input.Content == nil {
+			writeJSONError(w, http.StatusBadRequest, "send the new content as \"content\"")
+			return
+		}
+
+		// Looked up again with the lock held, the memo may have just
+		// been deleted
+		memoWrites.Lock()
+		memo, ok := memoByNumber(getKeyValue("MemoDir").(string), id)
+		if ok {
+			err = saveMemo(memo.Path, *input.Content)
+		}
+		memoWrites.Unlock()
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "there is no memo %d", id)
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "couldn't save the memo: %v", err)
+			return
+		}
+		m, err := toMemoJSON(memo, true)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m)
+	case http.MethodDelete:
+		if !apiWriteAllowed(w, r, false) {
+			return
+		}
+		memoWrites.Lock()
+		memo, ok := memoByNumber(getKeyValue("MemoDir").(string), id)
+		if ok {
+			err = deleteMemo(memo.Path)
+		}
+		memoWrites.Unlock()
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "there is no memo %d", id)
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "couldn't delete the memo: %v", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "%s is not allowed here", r.Method)
+	}
+}
+
+// apiSearch handles /api/v1/search?q=, returning the matching lines too
+func apiSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeJSONError(w, http.StatusMethodNotAllowed, "%s is not allowed here", r.Method)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, "q is missing")
+		return
+	}
+
+	results := []searchJSON{}
+	for _, result := range searchMemos(getKeyValue("MemoDir").(string), query) {
+		m, err := toMemoJSON(result.Memo, false)
+		if err != nil {
+			continue
+		}
+		results = append(results, searchJSON{Memo: m, Lines: result.Lines})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"query": query, "results": results})
+}
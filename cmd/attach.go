@@ -0,0 +1,233 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach <memo> <file...>",
+	Short: "Attach files to a memo",
+	Long:  `Copy screenshots, PDFs, logs... next to a memo and link them from it`,
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := matchMemo(args[0])
+		if filename == "" {
+			log.Fatalf("[%s]: Can't attach to memo, couldn't match any file", args[0])
+		}
+
+		// A file missing halfway would leave the ones before it copied
+		// but not linked
+		for _, src := range args[1:] {
+			if err := checkAttachment(src); err != nil {
+				log.Fatalf("Couldn't attach %s: %v", src, err)
+			}
+		}
+
+		var attached []string
+		for _, src := range args[1:] {
+			name, err := attachFile(filename, src)
+			if err != nil {
+				removeAttachments(filename, attached)
+				log.Fatalf("Couldn't attach %s, nothing was attached: %v", src, err)
+			}
+			attached = append(attached, name)
+		}
+
+		if err := linkAttachments(filename, attached); err != nil {
+			removeAttachments(filename, attached)
+			log.Fatalf("Couldn't link the attachments in %s, nothing was attached: %v", filename, err)
+		}
+		for i, name := range attached {
+			fmt.Printf("Attached %s as %s\n", args[i+1], name)
+		}
+
+		commitMsg := fmt.Sprintf("[Attach]: %s", getFileTitle(filename))
+		if err := commit(commitMsg, filename, assetsDir(filename)); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+}
+
+// Attachments of a memo live in a folder named after its file with this
+// suffix, a.md.assets for a.md
+const assetsSuffix = ".assets"
+
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".svg": true, ".webp": true, ".bmp": true, ".avif": true,
+}
+
+// assetsDir is the folder holding the attachments of a memo. It keeps
+// the extension so a.md and a.org don't share one.
+func assetsDir(memoPath string) string {
+	return memoPath + assetsSuffix
+}
+
+// isAssetsDir tells if the folder at path holds the attachments of a
+// memo, a folder of the user with the same suffix has no memo next to it
+func isAssetsDir(path string) bool {
+	memoPath, found := strings.CutSuffix(path, assetsSuffix)
+	return found && FileExists(memoPath)
+}
+
+// listAttachments returns the names of the files attached to a memo
+func listAttachments(memoPath string) []string {
+	entries, err := os.ReadDir(assetsDir(memoPath))
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// checkAttachment makes sure src is a file that can be read
+func checkAttachment(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", src)
+	}
+
+	return nil
+}
+
+// attachFile copies src into the attachments of the memo and returns
+// the name it got, an existing attachment is never overwritten.
+func attachFile(memoPath string, src string) (string, error) {
+	if err := checkAttachment(src); err != nil {
+		return "", err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	dir := assetsDir(memoPath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(src)
+	ext := filepath.Ext(base)
+	name := base
+	for i := 2; FileExists(filepath.Join(dir, name)); i++ {
+		name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(base, ext), i, ext)
+	}
+
+	out, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Half a file is no use
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	return name, nil
+}
+
+// removeAttachments takes back the attachments copied for a memo, the
+// folder goes too when nothing else is in it
+func removeAttachments(memoPath string, names []string) {
+	dir := assetsDir(memoPath)
+	for _, name := range names {
+		os.Remove(filepath.Join(dir, name))
+	}
+	os.Remove(dir)
+}
+
+// attachmentPath is how the memo refers to one of its attachments
+func attachmentPath(memoPath string, name string) string {
+	return url.PathEscape(filepath.Base(assetsDir(memoPath))) + "/" + url.PathEscape(name)
+}
+
+// linkAttachments appends a link to every attachment at the end of the
+// memo, written the way the format of the memo expects.
+func linkAttachments(memoPath string, names []string) error {
+	content, err := os.ReadFile(memoPath)
+	if err != nil {
+		return err
+	}
+
+	format := formatOf(memoPath).Name
+	var links string
+	for _, name := range names {
+		target := attachmentPath(memoPath, name)
+		image := imageExtensions[strings.ToLower(filepath.Ext(name))]
+
+		switch {
+		case format == "org":
+			links += fmt.Sprintf("[[file:%s][%s]]\n", target, name)
+		case format == "asciidoc" && image:
+			links += fmt.Sprintf("image::%s[%s]\n", target, name)
+		case format == "asciidoc":
+			links += fmt.Sprintf("link:%s[%s]\n", target, name)
+		case format == "rst" && image:
+			links += fmt.Sprintf(".. image:: %s\n", target)
+		case format == "rst":
+			links += fmt.Sprintf("`%s <%s>`_\n", name, target)
+		case format == "text":
+			links += target + "\n"
+		case image:
+			links += fmt.Sprintf("![%s](%s)\n", name, target)
+		default:
+			links += fmt.Sprintf("[%s](%s)\n", name, target)
+		}
+	}
+
+	text := strings.TrimRight(string(content), "\n") + "\n\n" + links
+
+	return os.WriteFile(memoPath, []byte(text), 0644)
+}
+
+// attachmentLinks points the links to attachments in the markdown of
+// a memo at prefix, /attachments/<number>/ for the server.
+func attachmentLinks(md []byte, memoPath string, prefix string) []byte {
+	base := url.PathEscape(filepath.Base(assetsDir(memoPath))) + "/"
+
+	text := string(md)
+	for _, from := range []string{"](./" + base, "](" + base} {
+		text = strings.ReplaceAll(text, from, "]("+prefix)
+	}
+
+	return []byte(text)
+}
@@ -0,0 +1,410 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// What a user is allowed to do on `memo serve`
+const (
+	roleRead  = "read"
+	roleWrite = "write"
+)
+
+const (
+	sessionCookie   = "memo_session"
+	sessionLifetime = 7 * 24 * time.Hour
+)
+
+// Compared against when the user doesn't exist so that unknown names
+// take as long to reject as wrong passwords
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("memo"), bcrypt.DefaultCost)
+
+type userKey struct{}
+
+// How many users with their password basic auth remembers
+const verifiedCacheSize = 64
+
+type session struct {
+	user    string
+	expires time.Time
+}
+
+// authenticator lets the users in the config in with a password, a
+// token or the session cookie of the login page. Without users in the
+// config everybody can do everything, like before there were users.
+//
+// The users are read again when the config file changes, so `memo user`
+// works on a running server.
+type authenticator struct {
+	configFile string
+
+	mu       sync.Mutex
+	users    []User
+	modTime  time.Time
+	sessions map[string]session
+	// Basic auth sends the password with every request, a bcrypt
+	// comparison each time would slow every page down. The least
+	// recently used ones go first when there are too many.
+	verified      map[string]string
+	verifiedOrder []string
+}
+
+func newAuthenticator(configFile string) *authenticator {
+	a := &authenticator{
+		configFile: configFile,
+		sessions:   map[string]session{},
+		verified:   map[string]string{},
+	}
+	if info, err := os.Stat(configFile); err == nil {
+		a.modTime = info.ModTime()
+	}
+	a.users = readConfig(configFile).Users
+
+	return a
+}
+
+// currentUsers returns the users, read again when the config changed.
+// A config that can't be read keeps the users from before.
+func (a *authenticator) currentUsers() []User {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.configFile)
+	if err != nil || info.ModTime().Equal(a.modTime) {
+		return a.users
+	}
+
+	var conf Config
+	if _, err := toml.DecodeFile(a.configFile, &conf); err != nil {
+		log.Printf("Keeping the users from before, couldn't read %s: %v", a.configFile, err)
+		return a.users
+	}
+	a.modTime = info.ModTime()
+
+	// Changed passwords and removed users have to log in again
+	old := map[string]string{}
+	for _, user := range a.users {
+		old[user.Name] = user.Password
+	}
+	still := map[string]bool{}
+	for _, user := range conf.Users {
+		still[user.Name] = old[user.Name] == user.Password
+	}
+	for token, s := range a.sessions {
+		if !still[s.user] {
+			delete(a.sessions, token)
+		}
+	}
+	a.verified = map[string]string{}
+	a.verifiedOrder = nil
+	if len(a.users) > 0 && len(conf.Users) == 0 {
+		log.Print("There are no users anymore, anybody who can reach the server can read and change the memos")
+	}
+	a.users = conf.Users
+
+	return a.users
+}
+
+func (a *authenticator) enabled() bool {
+	return len(a.currentUsers()) > 0
+}
+
+func (a *authenticator) findUser(name string) (User, bool) {
+	for _, user := range a.currentUsers() {
+		if user.Name == name {
+			return user, true
+		}
+	}
+
+	return User{}, false
+}
+
+// rememberPassword keeps a checked password for the next requests
+func (a *authenticator) rememberPassword(key string, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.verified[key]; !ok && len(a.verifiedOrder) >= verifiedCacheSize {
+		delete(a.verified, a.verifiedOrder[0])
+		a.verifiedOrder = a.verifiedOrder[1:]
+	}
+	a.verified[key] = name
+	a.touchPassword(key)
+}
+
+// touchPassword moves key to the end of the order, a.mu has to be held
+func (a *authenticator) touchPassword(key string) {
+	for i, k := range a.verifiedOrder {
+		if k == key {
+			a.verifiedOrder = append(a.verifiedOrder[:i], a.verifiedOrder[i+1:]...)
+			break
+		}
+	}
+	a.verifiedOrder = append(a.verifiedOrder, key)
+}
+
+// checkPassword looks the user up and compares the password
+func (a *authenticator) checkPassword(name string, password string) (User, bool) {
+	user, ok := a.findUser(name)
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return User{}, false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return User{}, false
+	}
+
+	return user, true
+}
+
+// identify works out who sent the request, from the session cookie,
+// a bearer token or basic auth in that order
+func (a *authenticator) identify(r *http.Request) (User, bool) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		a.mu.Lock()
+		s, ok := a.sessions[cookie.Value]
+		a.mu.Unlock()
+		if ok && time.Now().Before(s.expires) {
+			return a.findUser(s.user)
+		}
+	}
+
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		hash := hashToken(strings.TrimSpace(token))
+		for _, user := range a.currentUsers() {
+			if user.TokenHash != "" && subtle.ConstantTimeCompare([]byte(user.TokenHash), []byte(hash)) == 1 {
+				return user, true
+			}
+		}
+		return User{}, false
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		key := hashToken(name + "\x00" + password)
+		// Users are read before the cache, reading them can empty it
+		users := a.currentUsers()
+		a.mu.Lock()
+		verifiedName, seen := a.verified[key]
+		if seen {
+			a.touchPassword(key)
+		}
+		a.mu.Unlock()
+		if seen {
+			for _, user := range users {
+				if user.Name == verifiedName {
+					return user, true
+				}
+			}
+			return User{}, false
+		}
+
+		user, ok := a.checkPassword(name, password)
+		if ok {
+			a.rememberPassword(key, user.Name)
+		}
+		return user, ok
+	}
+
+	return User{}, false
+}
+
+// needsWrite tells the requests that change memos apart
+func needsWrite(r *http.Request) bool {
+	if r.URL.Path == "/edit" || strings.HasPrefix(r.URL.Path, "/edit/") || r.URL.Path == "/new" {
+		return true
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	return r.URL.Path != "/logout"
+}
+
+// protect only lets known users through, readers can't change memos
+func (a *authenticator) protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled() || r.URL.Path == "/login" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := a.identify(r)
+		if !ok {
+			a.challenge(w, r)
+			return
+		}
+
+		if needsWrite(r) && user.Role != roleWrite {
+			if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+				writeJSONError(w, http.StatusForbidden, "%s can only read memos", user.Name)
+			} else {
+				http.Error(w, "Your account can only read memos", http.StatusForbidden)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
+
+// challenge asks for credentials, browsers go to the login page and
+// everything else gets a 401 to answer with basic auth or a token
+func (a *authenticator) challenge(w http.ResponseWriter, r *http.Request) {
+	browser := strings.Contains(r.Header.Get("Accept"), "text/html")
+	if browser && r.Method == http.MethodGet && r.Header.Get("Authorization") == "" {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="memo", charset="UTF-8"`)
+	if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		writeJSONError(w, http.StatusUnauthorized, "log in with basic auth or a bearer token")
+		return
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// requestUser is who sent the request, nobody when there are no users
+func requestUser(r *http.Request) (User, bool) {
+	user, ok := r.Context().Value(userKey{}).(User)
+	return user, ok
+}
+
+// canWrite tells whether the pages should offer to change memos
+func canWrite(r *http.Request) bool {
+	user, ok := requestUser(r)
+	return !ok || user.Role == roleWrite
+}
+
+// loginPage shows the login form and starts a session on POST
+func (a *authenticator) loginPage(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	// Only go back to pages of this server
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/"
+	}
+
+	if !a.enabled() {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		renderLogin(w, r, next, "", "")
+	case http.MethodPost:
+		if !sameOrigin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		name := r.PostFormValue("name")
+		user, ok := a.checkPassword(name, r.PostFormValue("password"))
+		if !ok {
+			log.Printf("Failed login for %q from %s", name, r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			renderLogin(w, r, next, name, "Wrong name or password")
+			return
+		}
+
+		token, err := randomToken()
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		a.mu.Lock()
+		for t, s := range a.sessions {
+			if time.Now().After(s.expires) {
+				delete(a.sessions, t)
+			}
+		}
+		a.sessions[token] = session{user: user.Name, expires: time.Now().Add(sessionLifetime)}
+		a.mu.Unlock()
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token,
+			Path:     "/",
+			MaxAge:   int(sessionLifetime.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func renderLogin(w http.ResponseWriter, r *http.Request, next string, name string, note string) {
+	form := fmt.Sprintf(`<form class="login-form" method="post" action="/login">
+%s
+<input type="hidden" name="next" value="%s">
+<p><label>Name <input name="name" value="%s" autocomplete="username" required autofocus></label></p>
+<p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+<p><button>Log in</button></p>
+</form>`, editorNote(template.HTMLEscapeString(note)), template.HTMLEscapeString(next), template.HTMLEscapeString(name))
+
+	renderPage(w, r, inputData{Title: "Log in", Main: template.HTML(form)})
+}
+
+// logout ends the session of the login page
+func (a *authenticator) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		a.mu.Lock()
+		delete(a.sessions, cookie.Value)
+		a.mu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how API tokens are kept in the config, they are random
+// enough for sha256 where passwords need bcrypt
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,301 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// How a month is written in arguments and in ?month=
+const monthLayout = "2006-01"
+
+var calendarCmd = &cobra.Command{
+	Use:   "calendar [month]",
+	Short: "Show the memos of a month on a calendar",
+	Long: `Show a month with the days memos were written on highlighted, the
+current one by default. The month can be 2024-08, 8, aug or august 2024.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		selectMemo, _ := cmd.Flags().GetBool("select")
+
+		month, err := parseMonth(strings.Join(args, " "), time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		days := memoDays(getKeyValue("MemoDir").(string))
+		printCalendar(month, days, time.Now())
+
+		var memos []memoFile
+		var labels []string
+		for _, day := range monthWeeks(month) {
+			for _, date := range day {
+				if date.IsZero() {
+					continue
+				}
+				for _, memo := range days[date.Format(time.DateOnly)] {
+					memos = append(memos, memo)
+					labels = append(labels, fmt.Sprintf("%s  %d %s", date.Format("Jan 02"), memo.Number, strings.TrimSpace(getFileTitle(memo.Path))))
+				}
+			}
+		}
+
+		if len(memos) == 0 {
+			fmt.Println("No memos this month")
+			return
+		}
+		if !selectMemo {
+			fmt.Println(strings.Join(labels, "\n"))
+			return
+		}
+
+		var options []huh.Option[string]
+		for i, memo := range memos {
+			options = append(options, huh.NewOption(labels[i], memo.Path))
+		}
+		var picked string
+		if err := huh.NewSelect[string]().Title("Memo to view").Options(options...).Value(&picked).Run(); err != nil {
+			log.Fatal(err)
+		}
+		displayMemo(picked, "", false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calendarCmd)
+	calendarCmd.Flags().BoolP("select", "s", false, "Pick one of the memos of the month to view")
+}
+
+// parseMonth reads a month like 2024-08, 8, aug or "august 2024", the
+// current one when text is empty
+func parseMonth(text string, now time.Time) (time.Time, error) {
+	year, month := now.Year(), now.Month()
+
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if parsed, err := time.Parse(monthLayout, word); err == nil {
+			year, month = parsed.Year(), parsed.Month()
+			continue
+		}
+		if number, err := strconv.Atoi(word); err == nil {
+			switch {
+			case number >= 1 && number <= 12:
+				month = time.Month(number)
+			case len(word) == 4:
+				year = number
+			default:
+				return time.Time{}, fmt.Errorf("%q is neither a month nor a year", word)
+			}
+			continue
+		}
+
+		found := false
+		for m := time.January; m <= time.December; m++ {
+			name := strings.ToLower(m.String())
+			if len(word) >= 3 && strings.HasPrefix(name, word) {
+				month, found = m, true
+			}
+		}
+		if !found {
+			return time.Time{}, fmt.Errorf("couldn't read %q as a month, try 2024-08 or aug", text)
+		}
+	}
+
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.Local), nil
+}
+
+// memoDays groups the memos by the day they were written, as 2006-01-02
+func memoDays(memoDir string) map[string][]memoFile {
+	days := map[string][]memoFile{}
+	for _, memo := range readMemos(memoDir) {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		fm, _ := splitFrontMatter(string(content))
+		if date := memoDate(memo, fm); !date.IsZero() {
+			day := date.Format(time.DateOnly)
+			days[day] = append(days[day], memo)
+		}
+	}
+
+	for _, memos := range days {
+		sort.Slice(memos, func(i, j int) bool { return memos[i].Number < memos[j].Number })
+	}
+
+	return days
+}
+
+// monthWeeks lays a month out in weeks starting on Monday, the days of
+// the months around it are zero
+func monthWeeks(month time.Time) [][]time.Time {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local)
+	// Monday is the first column
+	offset := (int(first.Weekday()) + 6) % 7
+
+	var weeks [][]time.Time
+	week := make([]time.Time, 7)
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		column := (offset + day.Day() - 1) % 7
+		week[column] = day
+		if column == 6 {
+			weeks = append(weeks, week)
+			week = make([]time.Time, 7)
+		}
+	}
+	for _, day := range week {
+		if !day.IsZero() {
+			weeks = append(weeks, week)
+			break
+		}
+	}
+
+	return weeks
+}
+
+func printCalendar(month time.Time, days map[string][]memoFile, now time.Time) {
+	titleStyle := lipgloss.NewStyle().Bold(true).Width(20).Align(lipgloss.Center)
+	headStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	memoStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FAFAFA")).Background(lipgloss.Color("#7D56F4"))
+	todayStyle := lipgloss.NewStyle().Underline(true)
+
+	fmt.Println(titleStyle.Render(month.Format("January 2006")))
+	fmt.Println(headStyle.Render("Mo Tu We Th Fr Sa Su"))
+	for _, week := range monthWeeks(month) {
+		var cells []string
+		for _, day := range week {
+			if day.IsZero() {
+				cells = append(cells, "  ")
+				continue
+			}
+
+			cell := fmt.Sprintf("%2d", day.Day())
+			if len(days[day.Format(time.DateOnly)]) > 0 {
+				cell = memoStyle.Render(cell)
+			}
+			if day.Format(time.DateOnly) == now.Format(time.DateOnly) {
+				cell = todayStyle.Render(cell)
+			}
+			cells = append(cells, cell)
+		}
+		fmt.Println(strings.Join(cells, " "))
+	}
+	fmt.Println()
+}
+
+type calendarMemo struct {
+	URL    string
+	Title  string
+	Number int
+}
+
+type calendarDay struct {
+	Day   int
+	Date  string
+	Today bool
+	Memos []calendarMemo
+}
+
+type calendarData struct {
+	Month  string
+	Prev   string
+	Next   string
+	Weeks  [][]calendarDay
+	Months []indexLink
+}
+
+var calendarTemplate = template.Must(template.New("calendar").Parse(`<nav class="calendar-nav"><a href="{{ .Prev }}">&larr;</a> <strong>{{ .Month }}</strong> <a href="{{ .Next }}">&rarr;</a></nav>
+<table class="calendar">
+<thead><tr><th>Mon</th><th>Tue</th><th>Wed</th><th>Thu</th><th>Fri</th><th>Sat</th><th>Sun</th></tr></thead>
+<tbody>
+{{ range .Weeks }}<tr>{{ range . }}<td{{ if .Today }} class="today"{{ end }}>{{ if .Day }}<time datetime="{{ .Date }}">{{ .Day }}</time>
+{{ range .Memos }}<a href="{{ .URL }}" title="#{{ .Number }} {{ .Title }}">{{ .Title }}</a>
+{{ end }}{{ end }}</td>{{ end }}</tr>
+{{ end }}</tbody>
+</table>
+{{ with .Months }}<p class="calendar-months">{{ range . }}{{ if .Current }}<strong>{{ .Label }}</strong>{{ else }}<a href="{{ .URL }}">{{ .Label }}</a>{{ end }} {{ end }}</p>{{ end }}`))
+
+// calendarPage shows the memos of the month in ?month= on a calendar,
+// the current month by default
+func calendarPage(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	month, err := parseMonth(r.URL.Query().Get("month"), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	days := memoDays(getKeyValue("MemoDir").(string))
+	data := calendarData{
+		Month: month.Format("January 2006"),
+		Prev:  "/calendar?month=" + month.AddDate(0, -1, 0).Format(monthLayout),
+		Next:  "/calendar?month=" + month.AddDate(0, 1, 0).Format(monthLayout),
+	}
+
+	for _, week := range monthWeeks(month) {
+		var row []calendarDay
+		for _, date := range week {
+			if date.IsZero() {
+				row = append(row, calendarDay{})
+				continue
+			}
+
+			day := calendarDay{
+				Day:   date.Day(),
+				Date:  date.Format(time.DateOnly),
+				Today: date.Format(time.DateOnly) == now.Format(time.DateOnly),
+			}
+			for _, memo := range days[day.Date] {
+				day.Memos = append(day.Memos, calendarMemo{
+					URL:    memoURL(memo),
+					Title:  strings.TrimSpace(memoTitle(memo.Path)),
+					Number: memo.Number,
+				})
+			}
+			row = append(row, day)
+		}
+		data.Weeks = append(data.Weeks, row)
+	}
+
+	// Every month with memos, to jump around
+	counts := map[string]int{}
+	for day, memos := range days {
+		counts[day[:len(monthLayout)]] += len(memos)
+	}
+	var months []string
+	for m := range counts {
+		months = append(months, m)
+	}
+	sort.Strings(months)
+	for _, m := range months {
+		data.Months = append(data.Months, indexLink{
+			URL:     "/calendar?month=" + m,
+			Label:   fmt.Sprintf("%s (%d)", m, counts[m]),
+			Current: m == month.Format(monthLayout),
+		})
+	}
+
+	var out bytes.Buffer
+	if err := calendarTemplate.Execute(&out, data); err != nil {
+		log.Print(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	renderPage(w, r, inputData{
+		Title: "Calendar",
+		Main:  template.HTML(out.String()),
+	})
+}
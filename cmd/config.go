@@ -57,10 +57,47 @@ type Config struct {
 	EditConfig   bool   `toml:"editconfig"`
 	Git          bool   `toml:"git"`
 	StaticFiles  string `toml:"staticfiles"`
+	Template     string `toml:"template"`
+	Theme        string `toml:"theme"`
+	FileNames    string `toml:"filenames"`
+	Format       string `toml:"format"`
+	ServeAddr    string `toml:"serveaddr"`
+	Sanitize     string `toml:"sanitize"`
+	Highlight    string `toml:"highlight"`
+	TLSCert      string `toml:"tlscert"`
+	TLSKey       string `toml:"tlskey"`
+	// Where the memos are published, e.g. https://memo.example.org,
+	// feeds need it for their links
+	SiteURL string `toml:"siteurl"`
+	// Notebook is the name of the notebook used when --notebook
+	// isn't passed, an empty value means the top level MemoDir
+	Notebook  string     `toml:"notebook"`
+	Notebooks []Notebook `toml:"notebooks"`
+	// Users who can log in to `memo serve`, nobody has to when empty
+	Users []User `toml:"users"`
 	// A specialkey "config_dir" is where this config file lives
 	// it will be useless (redundant even) to add it in the file
 }
 
+// Notebook is a named memo collection with its own settings.
+// Empty settings fall back to the top level ones.
+type Notebook struct {
+	Name     string `toml:"name"`
+	MemoDir  string `toml:"memodir"`
+	Git      *bool  `toml:"git,omitempty"`
+	Template string `toml:"template,omitempty"`
+	Theme    string `toml:"theme,omitempty"`
+}
+
+// User can log in to `memo serve`. Only hashes of the password and
+// the API token are kept.
+type User struct {
+	Name      string `toml:"name"`
+	Password  string `toml:"password"`
+	TokenHash string `toml:"tokenhash,omitempty"`
+	Role      string `toml:"role"`
+}
+
 func getKeyValue(key string) any {
 	// Check if the environment variable is set
 	config_file_env := os.Getenv("GMEMOCONF")
@@ -89,10 +126,7 @@ func getKeyValue(key string) any {
 	// For all the keys that can be found in the config files
 	// 	or a typo?
 
-	var conf Config
-	if _, err := toml.DecodeFile(config_location, &conf); err != nil {
-		log.Fatal(err)
-	}
+	conf := activeConfig(readConfig(config_location))
 	value := reflect.ValueOf(conf)
 	field := value.FieldByName(key)
 
@@ -104,6 +138,47 @@ func getKeyValue(key string) any {
 	return nil
 }
 
+func readConfig(filename string) Config {
+	var conf Config
+	if _, err := toml.DecodeFile(filename, &conf); err != nil {
+		log.Fatal(err)
+	}
+
+	return conf
+}
+
+// activeConfig overlays the settings of the selected notebook on top
+// of the top level ones so the rest of memo doesn't have to care.
+func activeConfig(conf Config) Config {
+	name := notebookName
+	if name == "" {
+		name = conf.Notebook
+	}
+	if name == "" || name == defaultNotebook {
+		conf.Notebook = defaultNotebook
+		return conf
+	}
+
+	nb, ok := findNotebook(conf, name)
+	if !ok {
+		log.Fatalf("There is no notebook named %q, run `memo notebook ls` to see them", name)
+	}
+
+	conf.Notebook = nb.Name
+	conf.MemoDir = nb.MemoDir
+	if nb.Git != nil {
+		conf.Git = *nb.Git
+	}
+	if nb.Template != "" {
+		conf.Template = nb.Template
+	}
+	if nb.Theme != "" {
+		conf.Theme = nb.Theme
+	}
+
+	return conf
+}
+
 func editConfig() {
 	// Open the default editor instead of doing it myself
 	configFilename := getKeyValue("config_location").(string)
@@ -151,6 +226,37 @@ func viewConfig() {
 	editconf := strconv.FormatBool(getKeyValue("EditConfig").(bool))
 	configLoc := getKeyValue("config_location").(string)
 	staticFiles := getKeyValue("StaticFiles").(string)
+	notebook := getKeyValue("Notebook").(string)
+	theme := getKeyValue("Theme").(string)
+	tmpl := getKeyValue("Template").(string)
+	fileNames := getKeyValue("FileNames").(string)
+	format := getKeyValue("Format").(string)
+	serveAddr := getKeyValue("ServeAddr").(string)
+	sanitize, _ := sanitizeMode()
+	siteURL := getKeyValue("SiteURL").(string)
+	highlight := getKeyValue("Highlight").(string)
+
+	if theme == "" {
+		theme = "auto"
+	}
+	if tmpl == "" {
+		tmpl = "None"
+	}
+	if fileNames == "" {
+		fileNames = defaultFileNames
+	}
+	if format == "" {
+		format = memoFormats[0].Name
+	}
+	if serveAddr == "" {
+		serveAddr = defaultServeAddr
+	}
+	if highlight == "" {
+		highlight = defaultHighlightStyle
+	}
+	if siteURL == "" {
+		siteURL = "Where memo serve is reached"
+	}
 
 	if listfg == "" {
 		listfg = "NO Colour!"
@@ -160,6 +266,7 @@ func viewConfig() {
 	}
 
 	rows := [][]string{
+		{"Notebook", notebook},
 		{"Memo Directory", memoDir},
 		{"Config File Location", configLoc},
 		{"Editor", editor},
@@ -167,6 +274,14 @@ func viewConfig() {
 		{"Background Colour", listbg},
 		{"Config default to Edit", editconf},
 		{"Static files directory", staticFiles},
+		{"Theme", theme},
+		{"Template", tmpl},
+		{"File names", fileNames},
+		{"Default format", format},
+		{"Serve address", serveAddr},
+		{"Sanitize", sanitize},
+		{"Highlight style", highlight},
+		{"Site URL", siteURL},
 	}
 
 	di := table.New().
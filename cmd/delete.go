@@ -7,25 +7,20 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
 
 	"github.com/spf13/cobra"
 )
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete",
+	Use:   "delete <memo>",
 	Short: "Delete a memo",
 	Long:  `Delete a memo from the collection of your memos`,
 	Run: func(cmd *cobra.Command, args []string) {
 		argsPassed := len(args)
 		if argsPassed > 0 {
 
-			fistArgPassed, err := strconv.Atoi(args[0])
-			if err != nil {
-				log.Fatalf("You passed a non int")
-			}
-			filename := matchMemoNumber(fistArgPassed)
-			err = removeFile(filename, fistArgPassed)
+			filename := matchMemo(args[0])
+			err := removeFile(filename, args[0])
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -39,18 +34,38 @@ func init() {
 	rootCmd.AddCommand(deleteCmd)
 }
 
-func removeFile(filename string, memoNumber int) error {
+func removeFile(filename string, memoRef string) error {
 	// Confirm that the value given exists in the first place
 	ex := FileExists(filename)
 
 	var e error
 	if ex {
-		e = os.Remove(filename)
-		fmt.Printf("Deleted %s", filename)
+		e = deleteMemo(filename)
+		fmt.Printf("Deleted %s\n", filename)
 	} else {
-		fmt.Printf("[%d]: Can't delete memo, couldn't match any file\n", memoNumber)
+		fmt.Printf("[%s]: Can't delete memo, couldn't match any file\n", memoRef)
 		os.Exit(1)
 	}
 
 	return e
 }
+
+// deleteMemo removes a memo and its attachments, then commits that
+func deleteMemo(filename string) error {
+	title := memoTitle(filename)
+
+	if err := os.Remove(filename); err != nil {
+		return err
+	}
+
+	// The attachments go with the memo
+	if DirectoryExists(assetsDir(filename)) {
+		if err := os.RemoveAll(assetsDir(filename)); err != nil {
+			return err
+		}
+	}
+
+	commitMsg := fmt.Sprintf("[Delete]: %s", title)
+
+	return commit(commitMsg, filename, assetsDir(filename))
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Fences drawn as diagrams and the program drawing them
+var diagramTools = map[string]string{
+	"dot":      "dot",
+	"graphviz": "dot",
+	"mermaid":  "mmdc",
+}
+
+// Where the programs come from, for the hint shown without them
+var diagramPackages = map[string]string{
+	"dot":  "Graphviz",
+	"mmdc": "mermaid-cli",
+}
+
+// How long a program may take to draw one diagram
+const diagramTimeout = 20 * time.Second
+
+// How many drawn diagrams are kept and how many are drawn at once
+const (
+	diagramCacheSize = 256
+	diagramWorkers   = 2
+)
+
+// diagramCache keeps the drawn diagrams by their source, diagrams are
+// drawn again only when their source changes. The oldest are dropped
+// when it is full.
+var diagramCache = &diagramStore{drawn: map[[32]byte]*drawnDiagram{}}
+
+// diagramSlots keeps a page full of diagrams from starting a program
+// for every one of them at once
+var diagramSlots = make(chan struct{}, diagramWorkers)
+
+type drawnDiagram struct {
+	done chan struct{}
+	svg  []byte
+	err  error
+}
+
+type diagramStore struct {
+	mu    sync.Mutex
+	drawn map[[32]byte]*drawnDiagram
+	order [][32]byte
+}
+
+// get returns the diagram kept as key, draw draws it when there is none.
+// A diagram already being drawn for another page is waited for instead
+// of being drawn twice.
+func (s *diagramStore) get(key [32]byte, draw func() ([]byte, error)) ([]byte, error) {
+	s.mu.Lock()
+	d, ok := s.drawn[key]
+	if !ok {
+		d = &drawnDiagram{done: make(chan struct{})}
+		s.drawn[key] = d
+		s.order = append(s.order, key)
+		if len(s.order) > diagramCacheSize {
+			delete(s.drawn, s.order[0])
+			s.order = s.order[1:]
+		}
+	}
+	s.mu.Unlock()
+
+	if ok {
+		<-d.done
+		return d.svg, d.err
+	}
+
+	diagramSlots <- struct{}{}
+	d.svg, d.err = draw()
+	<-diagramSlots
+	close(d.done)
+
+	return d.svg, d.err
+}
+
+// renderDiagram is a render hook drawing ```dot and ```mermaid fences
+// as SVG images, without the program the source is shown
+func renderDiagram(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	block, ok := node.(*ast.CodeBlock)
+	if !ok {
+		return ast.GoToNext, false
+	}
+	info := strings.Fields(string(block.Info))
+	if len(info) == 0 {
+		return ast.GoToNext, false
+	}
+	kind := strings.ToLower(info[0])
+	tool, ok := diagramTools[kind]
+	if !ok {
+		return ast.GoToNext, false
+	}
+
+	svg, err := drawDiagram(tool, block.Literal)
+	if err == nil {
+		fmt.Fprintf(w, "<figure class=\"diagram\"><img src=\"data:image/svg+xml;base64,%s\" alt=\"%s diagram\"></figure>\n", base64.StdEncoding.EncodeToString(svg), kind)
+		return ast.GoToNext, true
+	}
+
+	fmt.Fprintf(w, "<figure class=\"diagram\"><pre class=\"diagram-source\">%s</pre><figcaption>%s</figcaption></figure>\n",
+		template.HTMLEscapeString(string(block.Literal)), template.HTMLEscapeString(err.Error()))
+
+	return ast.GoToNext, true
+}
+
+// drawDiagram returns the SVG of the source of a diagram drawn by tool
+func drawDiagram(tool string, src []byte) ([]byte, error) {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		// Not cached, the program can be installed while serving
+		return nil, fmt.Errorf("Install %s (%s) to draw this diagram", diagramPackages[tool], tool)
+	}
+
+	key := sha256.Sum256(append([]byte(tool+"\x00"), src...))
+	return diagramCache.get(key, func() ([]byte, error) { return runDiagramTool(tool, path, src) })
+}
+
+// runDiagramTool runs the program at path on the source of a diagram
+func runDiagramTool(tool string, path string, src []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagramTimeout)
+	defer cancel()
+
+	var svg []byte
+	var err error
+	var stderr bytes.Buffer
+	switch tool {
+	case "dot":
+		cmd := exec.CommandContext(ctx, path, "-Tsvg")
+		cmd.Stdin = bytes.NewReader(src)
+		cmd.Stderr = &stderr
+		svg, err = cmd.Output()
+	case "mmdc":
+		// mmdc only reads and writes files
+		var dir string
+		dir, err = os.MkdirTemp("", "memo-mermaid")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		in, out := filepath.Join(dir, "diagram.mmd"), filepath.Join(dir, "diagram.svg")
+		if err = os.WriteFile(in, src, 0600); err != nil {
+			return nil, err
+		}
+		cmd := exec.CommandContext(ctx, path, "-i", in, "-o", out, "-b", "transparent")
+		cmd.Stderr = &stderr
+		if err = cmd.Run(); err == nil {
+			svg, err = os.ReadFile(out)
+		}
+	}
+
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		message, _, _ = strings.Cut(message, "\n")
+		err = errors.New(tool + " couldn't draw this diagram: " + message)
+	}
+
+	return svg, err
+}
+
+var (
+	// a -> b -> c [label="x"] in dot
+	dotEdge  = regexp.MustCompile(`\s*(->|--)\s*`)
+	dotLabel = regexp.MustCompile(`^\s*("[^"]*"|[\w.]+)\s*\[[^\]]*label\s*=\s*"?([^",\]]*)"?`)
+	dotAttr  = regexp.MustCompile(`label\s*=\s*"?([^",\]]*)`)
+	// A[Start] -->|yes| B{Done?} and Alice->>Bob: Hi in mermaid
+	mermaidEdge  = regexp.MustCompile(`\s*(?:--\s*([^->|]+?)\s*)?(-->>|->>|-\.->|==>|-->|---|--x|--o|->|-x|-\))\s*(?:\|([^|]*)\|)?\s*`)
+	mermaidShape = regexp.MustCompile(`^([\w-]+)\s*(?:\[\[|\[\(|\(\[|\(\(|\[|\(|\{\{|\{|>)\s*"?(.*?)"?\s*(?:\]\]|\)\]|\]\)|\)\)|\]|\)|\}\}|\})$`)
+)
+
+// diagramText lists the edges of a diagram for the terminal, the
+// source is kept when there are none
+func diagramText(kind string, src string) string {
+	labels := map[string]string{}
+	var edges []string
+
+	for _, line := range strings.FieldsFunc(src, func(r rune) bool { return r == '\n' || r == ';' }) {
+		line = strings.TrimSpace(line)
+		if kind == "mermaid" {
+			if edge := mermaidText(line, labels); edge != "" {
+				edges = append(edges, edge)
+			}
+			continue
+		}
+
+		if m := dotLabel.FindStringSubmatch(line); m != nil && !dotEdge.MatchString(line) {
+			labels[strings.Trim(m[1], `"`)] = m[2]
+			continue
+		}
+		if !dotEdge.MatchString(line) {
+			continue
+		}
+		attrs := ""
+		if start := strings.Index(line, "["); start >= 0 {
+			if m := dotAttr.FindStringSubmatch(line[start:]); m != nil {
+				attrs = " (" + m[1] + ")"
+			}
+			line = line[:start]
+		}
+		var nodes []string
+		for _, name := range dotEdge.Split(line, -1) {
+			name = strings.Trim(strings.TrimSpace(name), `"{}`)
+			if label, ok := labels[name]; ok {
+				name = label
+			}
+			nodes = append(nodes, name)
+		}
+		edges = append(edges, strings.Join(nodes, " → ")+attrs)
+	}
+
+	if len(edges) == 0 {
+		return src
+	}
+
+	return strings.Join(edges, "\n") + "\n"
+}
+
+// mermaidText writes one line of a mermaid diagram as an edge
+func mermaidText(line string, labels map[string]string) string {
+	arrows := mermaidEdge.FindAllStringSubmatchIndex(line, -1)
+	if len(arrows) == 0 {
+		return ""
+	}
+
+	node := func(text string) string {
+		text = strings.TrimSpace(text)
+		if m := mermaidShape.FindStringSubmatch(text); m != nil {
+			labels[m[1]] = m[2]
+			return m[2]
+		}
+		if label, ok := labels[text]; ok {
+			return label
+		}
+		return text
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, a := range arrows {
+		out.WriteString(node(line[last:a[0]]))
+		out.WriteString(" → ")
+		for _, group := range [][2]int{{a[2], a[3]}, {a[6], a[7]}} {
+			if group[0] >= 0 && strings.TrimSpace(line[group[0]:group[1]]) != "" {
+				fmt.Fprintf(&out, "(%s) ", strings.TrimSpace(line[group[0]:group[1]]))
+			}
+		}
+		last = a[1]
+	}
+
+	// Sequence diagrams put the message after a colon
+	target, message, hasMessage := strings.Cut(line[last:], ":")
+	out.WriteString(node(target))
+	if hasMessage {
+		out.WriteString(": " + strings.TrimSpace(message))
+	}
+
+	return out.String()
+}
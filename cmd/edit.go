@@ -18,6 +18,8 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"os"
 	"strconv"
 
 	"github.com/spf13/cobra"
@@ -25,17 +27,21 @@ import (
 
 // editCmd represents the edit command
 var editCmd = &cobra.Command{
-	Use:   "edit",
+	Use:   "edit <memo>",
 	Short: "Edit your memo",
 	Long:  `Edit your memo easily`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) > 0 {
-			editMemo, _ := strconv.Atoi(args[0])
-			filename := matchMemoNumber(editMemo)
+			filename := matchMemo(args[0])
+			if filename == "" {
+				log.Fatalf("[%s]: Can't edit memo, couldn't match any file", args[0])
+			}
 			err := openEditor(filename)
 			if err == nil {
 				commitMsg := fmt.Sprintf("[Edit]: %s", getFileTitle(filename))
-				commit(commitMsg, filename)
+				if err := commit(commitMsg, filename); err != nil {
+					log.Fatal(err)
+				}
 			}
 		} else {
 			cmd.Help()
@@ -46,3 +52,23 @@ var editCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(editCmd)
 }
+
+// saveMemo replaces the content of a memo without the editor and
+// commits it the same way `memo edit` does.
+func saveMemo(filename string, content string) error {
+	// Keep the number numberMemo gave the memo if the new content
+	// dropped it
+	if number := storedNumber(filename); number > 0 {
+		if fm, _ := splitFrontMatter(content); fm.get("number") == "" {
+			content = setFrontMatter(content, "number", []string{strconv.Itoa(number)})
+		}
+	}
+
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		return err
+	}
+
+	commitMsg := fmt.Sprintf("[Edit]: %s", memoTitle(filename))
+
+	return commit(commitMsg, filename)
+}
@@ -0,0 +1,429 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export your memos to other formats",
+}
+
+var exportHTMLCmd = &cobra.Command{
+	Use:   "html",
+	Short: "Export the memos as a static website",
+	Long: `Render the memos like memo serve does into a directory of HTML pages
+that can be opened from the disk or put on any web server`,
+	Run: func(cmd *cobra.Command, args []string) {
+		out, _ := cmd.Flags().GetString("out")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		order, _ := cmd.Flags().GetString("sort")
+		base := getKeyValue("SiteURL").(string)
+		if cmd.Flag("base-url").Changed {
+			base, _ = cmd.Flags().GetString("base-url")
+		}
+
+		if _, err := sanitizeMode(); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := highlightStyle(); err != nil {
+			log.Fatal(err)
+		}
+
+		memos := exportedMemos(getKeyValue("MemoDir").(string), tags)
+		if len(memos) == 0 {
+			log.Fatal("No memos to export")
+		}
+
+		site := staticSite{out: out, order: order, base: strings.TrimRight(base, "/")}
+		if err := site.export(memos); err != nil {
+			log.Fatalf("Couldn't export the memos: %v", err)
+		}
+
+		fmt.Printf("Exported %d memos to %s\n", len(memos), filepath.Join(out, "index.html"))
+		if site.base == "" {
+			fmt.Println("The feeds link to the pages relative to themselves, give the address of the site with --base-url for links every reader follows")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportHTMLCmd)
+
+	exportHTMLCmd.Flags().StringP("out", "o", "", "Directory to write the website to")
+	exportHTMLCmd.Flags().StringSliceP("tag", "t", nil, "Only export the memos with one of these tags")
+	exportHTMLCmd.Flags().String("sort", indexSorts[0].value, "Order of the memo lists: number, created, modified or title, - reverses it")
+	exportHTMLCmd.Flags().String("base-url", "", "Address the site is published at, for the links in the feeds (default siteurl in the config)")
+	exportHTMLCmd.MarkFlagRequired("out")
+}
+
+// exportedMemos are the memos with any of tags, every memo without tags
+func exportedMemos(memoDir string, tags []string) []memoFile {
+	if len(tags) == 0 {
+		return readMemos(memoDir)
+	}
+
+	for i, tag := range tags {
+		tags[i] = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	}
+
+	var memos []memoFile
+	for _, memo := range readMemos(memoDir) {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		for _, tag := range memoTags(string(content)) {
+			if containsString(tags, tag) {
+				memos = append(memos, memo)
+				break
+			}
+		}
+	}
+
+	return memos
+}
+
+// staticSite writes the pages of memo serve as files. Every page links
+// to the others relative to itself so the site works from any place:
+//
+//	index.html                  the memos at the top
+//	folder/<path>/index.html    the memos in a folder
+//	memo/<number>-<slug>.html   a memo
+//	tag/index.html              the tags
+//	tag/<tag>.html              the memos with a tag
+//	attachments/<number>/       the files attached to a memo
+//	feed.atom, .rss, .json      the feeds of the newest memos
+//	tag/<tag>.atom, .rss, .json the same for the memos with a tag
+//	script.js                   the scripts in StaticFiles
+type staticSite struct {
+	out   string
+	order string
+	// Where the site is published, links in the feeds are relative
+	// when it isn't known
+	base string
+}
+
+func (s staticSite) export(memos []memoFile) error {
+	if err := os.MkdirAll(s.out, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.out, "script.js"), []byte(serveStaticFile("js")), 0644); err != nil {
+		return err
+	}
+
+	tagged := map[string][]memoFile{}
+	folders := map[string]bool{"": true}
+	for _, memo := range memos {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			return err
+		}
+		if err := s.memoPage(memo, content); err != nil {
+			return err
+		}
+
+		for _, tag := range memoTags(string(content)) {
+			tagged[tag] = append(tagged[tag], memo)
+		}
+		for folder := memo.Folder; folder != "."; folder = path.Dir(folder) {
+			folders[folder] = true
+		}
+	}
+
+	for folder := range folders {
+		if err := s.folderPage(folder, folders, memos); err != nil {
+			return err
+		}
+	}
+
+	if err := s.feeds("", memos); err != nil {
+		return err
+	}
+	for tag, tagMemos := range tagged {
+		if err := s.feeds(tag, tagMemos); err != nil {
+			return err
+		}
+	}
+
+	return s.tagPages(tagged)
+}
+
+// feeds writes the atom, rss and json feeds of memos, next to the tag
+// page for the memos with a tag
+func (s staticSite) feeds(tag string, memos []memoFile) error {
+	name, home := "feed", "index.html"
+	if tag != "" {
+		home = tagPagePath(tag)
+		name = strings.TrimSuffix(home, ".html")
+	}
+
+	prefix := relRoot(name)
+	if s.base != "" {
+		prefix = s.base + "/"
+	}
+	links := feedLinks{
+		prefix: prefix,
+		memo:   func(memo memoFile) string { return folderURL(memoPagePath(memo)) },
+		tag:    func(tag string) string { return folderURL(tagPagePath(tag)) },
+		attachments: func(memo memoFile) string {
+			return fmt.Sprintf("attachments/%d/", memo.Number)
+		},
+	}
+
+	for kind := range feedTypes {
+		file, err := s.file(name + "." + kind)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+
+		self := prefix + folderURL(name+"."+kind)
+		err = writeFeed(f, kind, newFeed(feedTitle(tag), prefix+folderURL(home), self, memos, links))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// memoPage writes the page of a memo and copies its attachments
+func (s staticSite) memoPage(memo memoFile, content []byte) error {
+	page := memoPagePath(memo)
+	root := relRoot(page)
+
+	attachments := fmt.Sprintf("attachments/%d", memo.Number)
+	for _, name := range listAttachments(memo.Path) {
+		if err := copyFile(filepath.Join(assetsDir(memo.Path), name), filepath.Join(s.out, filepath.FromSlash(attachments), name)); err != nil {
+			return err
+		}
+	}
+
+	userHTML := memoHTML(memo, content, root+attachments+"/", func(tag string) string {
+		return root + folderURL(tagPagePath(tag))
+	})
+
+	return s.write(page, inputData{
+		Title:       getFileTitle(memo.Path),
+		Breadcrumbs: s.breadcrumbs(root, memo.Folder),
+		Main:        template.HTML(userHTML),
+		TOC:         tocHTML(memoMarkdown(memo.Path, content)),
+	})
+}
+
+// folderPage lists the exported memos and sub folders of folder
+func (s staticSite) folderPage(folder string, folders map[string]bool, memos []memoFile) error {
+	page := folderPagePath(folder)
+	root := relRoot(page)
+
+	var subs []indexLink
+	for sub := range folders {
+		parent := path.Dir(sub)
+		if parent == "." {
+			parent = ""
+		}
+		if sub != "" && parent == folder {
+			subs = append(subs, indexLink{URL: root + folderURL(folderPagePath(sub)), Label: path.Base(sub)})
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Label < subs[j].Label })
+
+	var inFolder []memoFile
+	for _, memo := range memos {
+		if memo.Folder == folder {
+			inFolder = append(inFolder, memo)
+		}
+	}
+
+	list, err := s.memoList(root, subs, inFolder)
+	if err != nil {
+		return err
+	}
+
+	title := "Home"
+	if folder != "" {
+		title = folder
+	}
+
+	return s.write(page, inputData{Title: title, Breadcrumbs: s.breadcrumbs(root, folder), Main: list})
+}
+
+// tagPages writes the list of tags and a page for each of them
+func (s staticSite) tagPages(tagged map[string][]memoFile) error {
+	var tags []string
+	for tag := range tagged {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	page := "tag/index.html"
+	root := relRoot(page)
+	content := ""
+	for _, tag := range tags {
+		href := root + folderURL(tagPagePath(tag))
+		content += fmt.Sprintf("<a class=\"main-link\" href=\"%s\">#%s (%d)</a><br/>", href, template.HTMLEscapeString(tag), len(tagged[tag]))
+	}
+	if content == "" {
+		content = "<p>None of these memos has a tag.</p>"
+	}
+	if err := s.write(page, inputData{Title: "Tags", Breadcrumbs: s.breadcrumbs(root, ""), Main: template.HTML(content)}); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		page := tagPagePath(tag)
+		root := relRoot(page)
+		list, err := s.memoList(root, nil, tagged[tag])
+		if err != nil {
+			return err
+		}
+		if err := s.write(page, inputData{Title: "#" + tag, Breadcrumbs: s.breadcrumbs(root, ""), Main: list}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// memoList is the index of memo serve with links between the files
+func (s staticSite) memoList(root string, folders []indexLink, memos []memoFile) (template.HTML, error) {
+	entries := indexEntries(memos)
+	sortIndex(entries, s.order)
+	addExcerpts(entries)
+
+	for i := range entries {
+		entry := &entries[i]
+		entry.URL = root + folderURL(memoPagePath(entry.memo))
+		for j := range entry.Tags {
+			entry.Tags[j].URL = root + folderURL(tagPagePath(entry.Tags[j].Label))
+		}
+	}
+
+	return renderIndex(indexData{Folders: folders, Memos: entries})
+}
+
+func (s staticSite) breadcrumbs(root string, folder string) template.HTML {
+	return crumbTrail(folder, root+"index.html", func(f string) string {
+		return root + folderURL(folderPagePath(f))
+	})
+}
+
+// write fills base.html for the page at the slash separated path page
+func (s staticSite) write(page string, data inputData) error {
+	data.Root = relRoot(page)
+	data.Static = true
+
+	file, err := s.file(page)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := executeBase(f, data); err != nil {
+		return fmt.Errorf("%s: %v", page, err)
+	}
+
+	return f.Close()
+}
+
+func memoPagePath(memo memoFile) string {
+	return fmt.Sprintf("memo/%d-%s.html", memo.Number, slugify(getFileTitle(memo.Path)))
+}
+
+func folderPagePath(folder string) string {
+	if folder == "" {
+		return "index.html"
+	}
+
+	return "folder/" + folder + "/index.html"
+}
+
+// Tags can have slashes, they end up in sub directories of tag/. Tags
+// come from the front matter as they are, every part is escaped and
+// empty, . and .. parts are dropped so none leads out of tag/.
+func tagPagePath(tag string) string {
+	var parts []string
+	for _, part := range strings.Split(tag, "/") {
+		if part != "" && part != "." && part != ".." {
+			parts = append(parts, url.PathEscape(part))
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{"_"}
+	}
+
+	return "tag/" + strings.Join(parts, "/") + ".html"
+}
+
+// file is where the slash separated path page goes, it has to stay
+// inside the output directory
+func (s staticSite) file(page string) (string, error) {
+	file := filepath.Join(s.out, filepath.FromSlash(page))
+	rel, err := filepath.Rel(s.out, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: outside of %s", page, s.out)
+	}
+
+	return file, nil
+}
+
+// relRoot leads from the page back to the top of the site
+func relRoot(page string) string {
+	return strings.Repeat("../", strings.Count(page, "/"))
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
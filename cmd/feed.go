@@ -0,0 +1,370 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Newest memos put in a feed
+const feedSize = 50
+
+// The feeds memo writes, by extension
+var feedTypes = map[string]string{
+	"atom": "application/atom+xml; charset=utf-8",
+	"rss":  "application/rss+xml; charset=utf-8",
+	"json": "application/feed+json; charset=utf-8",
+}
+
+// feedLinks says where the pages a feed points at are. Every link is
+// prefix followed by a path of the site.
+type feedLinks struct {
+	prefix      string
+	memo        func(memo memoFile) string
+	tag         func(tag string) string
+	attachments func(memo memoFile) string
+}
+
+type feed struct {
+	Title   string
+	Home    string
+	Self    string
+	Updated time.Time
+	Items   []feedItem
+}
+
+type feedItem struct {
+	ID        string
+	Title     string
+	URL       string
+	Published time.Time
+	Updated   time.Time
+	Tags      []string
+	HTML      string
+}
+
+// newFeed collects the newest memos, with their content rendered
+func newFeed(title string, home string, self string, memos []memoFile, links feedLinks) feed {
+	f := feed{Title: title, Home: home, Self: self}
+
+	// Rendering is the slow part, only the newest memos get it
+	type entry struct {
+		item    feedItem
+		memo    memoFile
+		content []byte
+	}
+	var entries []entry
+	for _, memo := range memos {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(memo.Path)
+		if err != nil {
+			continue
+		}
+		fm, _ := splitFrontMatter(string(content))
+
+		item := feedItem{
+			Title:     strings.TrimSpace(memoTitle(memo.Path)),
+			URL:       links.prefix + links.memo(memo),
+			Published: memoDate(memo, fm),
+			Updated:   info.ModTime(),
+			Tags:      memoTags(string(content)),
+		}
+		// The number stays when the title and the file name change
+		item.ID = fmt.Sprintf("%smemo/%d", links.prefix, memo.Number)
+		if !strings.Contains(links.prefix, "://") {
+			item.ID = fmt.Sprintf("urn:memo:%d", memo.Number)
+		}
+		if item.Updated.Before(item.Published) {
+			item.Updated = item.Published
+		}
+
+		entries = append(entries, entry{item: item, memo: memo, content: content})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].item.Published.After(entries[j].item.Published) })
+	if len(entries) > feedSize {
+		entries = entries[:feedSize]
+	}
+
+	for _, e := range entries {
+		e.item.HTML = memoHTML(e.memo, e.content, links.prefix+links.attachments(e.memo), func(tag string) string {
+			return links.prefix + links.tag(tag)
+		})
+		f.Items = append(f.Items, e.item)
+	}
+
+	for _, item := range f.Items {
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+	}
+	if f.Updated.IsZero() {
+		f.Updated = time.Now()
+	}
+
+	return f
+}
+
+// writeFeed writes f as an atom, rss or json feed
+func writeFeed(w io.Writer, kind string, f feed) error {
+	switch kind {
+	case "atom":
+		return writeAtom(w, f)
+	case "rss":
+		return writeRSS(w, f)
+	case "json":
+		return writeJSONFeed(w, f)
+	}
+
+	return fmt.Errorf("no %q feeds, only atom, rss and json", kind)
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func writeAtom(w io.Writer, f feed) error {
+	atom := atomFeed{
+		Title:   f.Title,
+		ID:      f.Self,
+		Updated: f.Updated.Format(time.RFC3339),
+		Author:  getKeyValue("programName").(string),
+		Links: []atomLink{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Home, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, item := range f.Items {
+		entry := atomEntry{
+			Title:     item.Title,
+			ID:        item.ID,
+			Link:      atomLink{Href: item.URL, Rel: "alternate", Type: "text/html"},
+			Published: item.Published.Format(time.RFC3339),
+			Updated:   item.Updated.Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Body: item.HTML},
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return writeXML(w, atom)
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssFeed struct {
+	XMLName       xml.Name  `xml:"rss"`
+	Version       string    `xml:"version,attr"`
+	Title         string    `xml:"channel>title"`
+	Link          string    `xml:"channel>link"`
+	Description   string    `xml:"channel>description"`
+	LastBuildDate string    `xml:"channel>lastBuildDate"`
+	Items         []rssItem `xml:"channel>item"`
+}
+
+func writeRSS(w io.Writer, f feed) error {
+	rss := rssFeed{
+		Version:       "2.0",
+		Title:         f.Title,
+		Link:          f.Home,
+		Description:   f.Title,
+		LastBuildDate: f.Updated.Format(time.RFC1123Z),
+	}
+	for _, item := range f.Items {
+		rss.Items = append(rss.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{Value: item.ID},
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			Categories:  item.Tags,
+			Description: item.HTML,
+		})
+	}
+
+	return writeXML(w, rss)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// https://www.jsonfeed.org/version/1.1/
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+func writeJSONFeed(w io.Writer, f feed) error {
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Home,
+		FeedURL:     f.Self,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range f.Items {
+		out.Items = append(out.Items, jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentHTML:   item.HTML,
+			DatePublished: item.Published.Format(time.RFC3339),
+			DateModified:  item.Updated.Format(time.RFC3339),
+			Tags:          item.Tags,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(out)
+}
+
+// feedTitle names the feed of the memos with tag, every memo without
+func feedTitle(tag string) string {
+	if tag == "" {
+		return "Memo"
+	}
+
+	return "Memo #" + tag
+}
+
+// serveFeed serves /feed.atom, /feed.rss and /feed.json, ?tag= keeps
+// the memos with that tag
+func serveFeed(w http.ResponseWriter, r *http.Request) {
+	kind := strings.TrimPrefix(path.Ext(r.URL.Path), ".")
+	contentType, ok := feedTypes[kind]
+	if !ok || r.URL.Path != "/feed."+kind {
+		displayCustom404(w, r)
+		return
+	}
+
+	tag := strings.ToLower(strings.TrimPrefix(r.URL.Query().Get("tag"), "#"))
+	var memos []memoFile
+	for _, memo := range readMemos(getKeyValue("MemoDir").(string)) {
+		if tag != "" {
+			content, err := os.ReadFile(memo.Path)
+			if err != nil || !containsString(memoTags(string(content)), tag) {
+				continue
+			}
+		}
+		memos = append(memos, memo)
+	}
+
+	prefix := siteURL(r) + "/"
+	links := feedLinks{
+		prefix: prefix,
+		memo:   func(memo memoFile) string { return strings.TrimPrefix(memoURL(memo), "/") },
+		tag:    func(tag string) string { return "tag/" + url.PathEscape(tag) },
+		attachments: func(memo memoFile) string {
+			return fmt.Sprintf("attachments/%d/", memo.Number)
+		},
+	}
+	home := prefix
+	if tag != "" {
+		home += links.tag(tag)
+	}
+	f := newFeed(feedTitle(tag), home, prefix+strings.TrimPrefix(r.URL.RequestURI(), "/"), memos, links)
+
+	w.Header().Set("Content-Type", contentType)
+	if err := writeFeed(w, kind, f); err != nil {
+		log.Print(err)
+	}
+}
+
+// siteURL is siteurl in the config, else the address the request
+// came to
+func siteURL(r *http.Request) string {
+	if site := getKeyValue("SiteURL").(string); site != "" {
+		return strings.TrimRight(site, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,513 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// memoFormat is a markup language memos can be written in. Everything
+// is rendered by converting it to markdown first so the terminal and
+// the browser only have to deal with markdown.
+type memoFormat struct {
+	Name       string
+	Extensions []string
+	// heading is how a new memo with the given title starts
+	heading    func(title string) string
+	title      func(lines []string) string
+	toMarkdown func(content string) string
+}
+
+var memoFormats = []memoFormat{
+	{
+		Name:       "markdown",
+		Extensions: []string{".md", ".markdown"},
+		heading:    func(title string) string { return "# " + title + "\n\n" },
+		title:      markdownTitle,
+		toMarkdown: func(content string) string { return content },
+	},
+	{
+		Name:       "org",
+		Extensions: []string{".org"},
+		heading:    func(title string) string { return "#+TITLE: " + title + "\n\n" },
+		title:      orgTitle,
+		toMarkdown: orgToMarkdown,
+	},
+	{
+		Name:       "asciidoc",
+		Extensions: []string{".adoc", ".asciidoc"},
+		heading:    func(title string) string { return "= " + title + "\n\n" },
+		title:      asciidocTitle,
+		toMarkdown: asciidocToMarkdown,
+	},
+	{
+		Name:       "rst",
+		Extensions: []string{".rst"},
+		heading: func(title string) string {
+			return title + "\n" + strings.Repeat("=", len([]rune(title))) + "\n\n"
+		},
+		title:      rstTitle,
+		toMarkdown: rstToMarkdown,
+	},
+	{
+		Name:       "text",
+		Extensions: []string{".txt"},
+		heading:    func(title string) string { return title + "\n\n" },
+		title:      firstLine,
+		toMarkdown: textToMarkdown,
+	},
+}
+
+// formatOf picks the format of a memo by its extension, anything
+// unknown is treated as markdown.
+func formatOf(filename string) memoFormat {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, format := range memoFormats {
+		for _, e := range format.Extensions {
+			if e == ext {
+				return format
+			}
+		}
+	}
+
+	return memoFormats[0]
+}
+
+// findFormat looks up a format by its name or one of its extensions
+func findFormat(name string) (memoFormat, error) {
+	if name == "" {
+		return memoFormats[0], nil
+	}
+
+	name = strings.ToLower(name)
+	for _, format := range memoFormats {
+		if format.Name == name {
+			return format, nil
+		}
+		for _, ext := range format.Extensions {
+			if strings.TrimPrefix(ext, ".") == strings.TrimPrefix(name, ".") {
+				return format, nil
+			}
+		}
+	}
+
+	return memoFormat{}, fmt.Errorf("%q is not a supported format, use one of %s", name, strings.Join(formatNames(), ", "))
+}
+
+func formatNames() []string {
+	var names []string
+	for _, format := range memoFormats {
+		names = append(names, format.Name)
+	}
+
+	return names
+}
+
+// memoExtensions lists the extension of every supported format
+func memoExtensions() []string {
+	var exts []string
+	for _, format := range memoFormats {
+		exts = append(exts, format.Extensions...)
+	}
+
+	return exts
+}
+
+// memoMarkdown returns the content of a memo as markdown without its
+// front matter, ready for glamour or mdToHTML.
+func memoMarkdown(filename string, content []byte) []byte {
+	_, body := splitFrontMatter(string(content))
+	return []byte(formatOf(filename).toMarkdown(body))
+}
+
+func firstLine(lines []string) string {
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			return trimmed
+		}
+	}
+
+	return ""
+}
+
+func markdownTitle(lines []string) string {
+	return strings.TrimSpace(strings.TrimLeft(firstLine(lines), "#"))
+}
+
+// Inline markup shared by the converters below
+var (
+	orgLinkDesc  = regexp.MustCompile(`\[\[([^\]]+)\]\[([^\]]+)\]\]`)
+	orgLink      = regexp.MustCompile(`\[\[([^\]]+)\]\]`)
+	orgBold      = regexp.MustCompile(`(^|[\s(])\*([^\s*][^*]*?)\*($|[\s.,;:!?)])`)
+	orgItalic    = regexp.MustCompile(`(^|[\s(])/([^\s/][^/]*?)/($|[\s.,;:!?)])`)
+	orgCode      = regexp.MustCompile(`(^|[\s(])[=~]([^\s=~][^=~]*?)[=~]($|[\s.,;:!?)])`)
+	orgHeading   = regexp.MustCompile(`^(\*+)\s+(.*)$`)
+	orgKeyword   = regexp.MustCompile(`(?i)^#\+(\w+):\s*(.*)$`)
+	orgOrdered   = regexp.MustCompile(`^(\s*)\d+[.)]\s+(.*)$`)
+	orgUnordered = regexp.MustCompile(`^(\s*)[-+]\s+(.*)$`)
+
+	adocHeading   = regexp.MustCompile(`^(=+)\s+(.*)$`)
+	adocImage     = regexp.MustCompile(`image::?([^\s\[]+)\[([^\]]*)\]`)
+	adocLink      = regexp.MustCompile(`(?:link:)?((?:https?|ftp|mailto|file):[^\s\[]*|[^\s\[:]+\.\w+)\[([^\]]*)\]`)
+	adocBold      = regexp.MustCompile(`(^|[\s(])\*([^\s*][^*]*?)\*($|[\s.,;:!?)])`)
+	adocItalic    = regexp.MustCompile(`(^|[\s(])_([^\s_][^_]*?)_($|[\s.,;:!?)])`)
+	adocUnordered = regexp.MustCompile(`^(\*+|-)\s+(.*)$`)
+	adocOrdered   = regexp.MustCompile(`^(\.+)\s+(.*)$`)
+	adocSource    = regexp.MustCompile(`^\[source(?:,\s*([\w+#-]+))?.*\]$`)
+
+	rstLink       = regexp.MustCompile("`([^`<]+?)\\s*<([^>]+)>`_{1,2}")
+	rstCode       = regexp.MustCompile("``([^`]+)``")
+	rstCodeBlock  = regexp.MustCompile(`^\.\.\s+(?:code-block|code|sourcecode)::\s*(\S*)`)
+	rstDirective  = regexp.MustCompile(`^\.\.\s`)
+	rstImage      = regexp.MustCompile(`^\.\.\s+(?:image|figure)::\s*(\S+)`)
+	rstUnordered  = regexp.MustCompile(`^(\s*)[*+-]\s+(.*)$`)
+	rstOrdered    = regexp.MustCompile(`^(\s*)(?:\d+|#)\.\s+(.*)$`)
+	markdownChars = regexp.MustCompile("([\\\\`*_\\[\\]<>#|~])")
+	textListStart = regexp.MustCompile(`^(\s*)([-+=]|\d+\.)(\s)`)
+)
+
+func orgInline(line string) string {
+	line = orgLinkDesc.ReplaceAllStringFunc(line, func(match string) string {
+		m := orgLinkDesc.FindStringSubmatch(match)
+		return "[" + m[2] + "](" + strings.TrimPrefix(m[1], "file:") + ")"
+	})
+	line = orgLink.ReplaceAllStringFunc(line, func(match string) string {
+		target := strings.TrimPrefix(orgLink.FindStringSubmatch(match)[1], "file:")
+		// Links without a description to images show the image
+		if imageExtensions[strings.ToLower(filepath.Ext(target))] {
+			return "![](" + target + ")"
+		}
+		return "[" + target + "](" + target + ")"
+	})
+	line = orgCode.ReplaceAllString(line, "$1`$2`$3")
+	line = orgBold.ReplaceAllString(line, "$1**$2**$3")
+	line = orgItalic.ReplaceAllString(line, "$1*$2*$3")
+
+	return line
+}
+
+func orgTitle(lines []string) string {
+	for _, line := range lines {
+		if m := orgKeyword.FindStringSubmatch(strings.TrimSpace(line)); m != nil && strings.EqualFold(m[1], "title") {
+			return strings.TrimSpace(m[2])
+		}
+	}
+	for _, line := range lines {
+		if m := orgHeading.FindStringSubmatch(line); m != nil {
+			return strings.TrimSpace(m[2])
+		}
+	}
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#+") {
+			return trimmed
+		}
+	}
+
+	return ""
+}
+
+func orgToMarkdown(content string) string {
+	var out []string
+	inBlock := false
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		upper := strings.ToUpper(trimmed)
+
+		switch {
+		case strings.HasPrefix(upper, "#+BEGIN_SRC"), strings.HasPrefix(upper, "#+BEGIN_EXAMPLE"):
+			lang := ""
+			if fields := strings.Fields(trimmed); len(fields) > 1 && strings.HasPrefix(upper, "#+BEGIN_SRC") {
+				lang = fields[1]
+			}
+			out = append(out, "```"+lang)
+			inBlock = true
+		case strings.HasPrefix(upper, "#+END_SRC"), strings.HasPrefix(upper, "#+END_EXAMPLE"):
+			out = append(out, "```")
+			inBlock = false
+		case inBlock:
+			out = append(out, line)
+		case strings.HasPrefix(upper, "#+BEGIN_QUOTE"), strings.HasPrefix(upper, "#+END_QUOTE"):
+			out = append(out, "")
+		case orgKeyword.MatchString(trimmed):
+			m := orgKeyword.FindStringSubmatch(trimmed)
+			if strings.EqualFold(m[1], "title") {
+				out = append(out, "# "+orgInline(m[2]))
+			}
+		case strings.HasPrefix(trimmed, "# ") || trimmed == "#":
+			// Comments
+		case orgHeading.MatchString(line):
+			m := orgHeading.FindStringSubmatch(line)
+			out = append(out, strings.Repeat("#", len(m[1]))+" "+orgInline(m[2]))
+		case orgOrdered.MatchString(line):
+			m := orgOrdered.FindStringSubmatch(line)
+			out = append(out, m[1]+"1. "+orgInline(m[2]))
+		case orgUnordered.MatchString(line):
+			m := orgUnordered.FindStringSubmatch(line)
+			out = append(out, m[1]+"- "+orgInline(m[2]))
+		case strings.HasPrefix(trimmed, ": "):
+			// Fixed width lines
+			out = append(out, "    "+strings.TrimPrefix(trimmed, ": "))
+		default:
+			out = append(out, orgInline(line))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func adocInline(line string) string {
+	line = adocImage.ReplaceAllString(line, "![$2]($1)")
+	line = adocLink.ReplaceAllStringFunc(line, func(match string) string {
+		m := adocLink.FindStringSubmatch(match)
+		if m[2] == "" {
+			return "<" + m[1] + ">"
+		}
+		return "[" + m[2] + "](" + m[1] + ")"
+	})
+	line = adocBold.ReplaceAllString(line, "$1**$2**$3")
+	line = adocItalic.ReplaceAllString(line, "$1*$2*$3")
+
+	return line
+}
+
+func asciidocTitle(lines []string) string {
+	for _, line := range lines {
+		if m := adocHeading.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			return strings.TrimSpace(m[2])
+		}
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "//") && !strings.HasPrefix(trimmed, ":") {
+			return trimmed
+		}
+	}
+
+	return ""
+}
+
+func asciidocToMarkdown(content string) string {
+	var out []string
+	lang := ""
+	inBlock := false
+	delimiter := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case inBlock && trimmed == delimiter:
+			out = append(out, "```")
+			inBlock = false
+		case inBlock:
+			out = append(out, line)
+		case adocSource.MatchString(trimmed):
+			lang = adocSource.FindStringSubmatch(trimmed)[1]
+		case trimmed == "----" || trimmed == "....":
+			out = append(out, "```"+lang)
+			inBlock = true
+			delimiter = trimmed
+			lang = ""
+		case trimmed == "____":
+			out = append(out, "")
+		case strings.HasPrefix(trimmed, "//"):
+			// Comments
+		case strings.HasPrefix(trimmed, ":") && strings.Count(trimmed, ":") >= 2 && !strings.Contains(trimmed, " :"):
+			// Document attributes
+		case adocHeading.MatchString(trimmed):
+			m := adocHeading.FindStringSubmatch(trimmed)
+			out = append(out, strings.Repeat("#", len(m[1]))+" "+adocInline(m[2]))
+		case adocUnordered.MatchString(trimmed):
+			m := adocUnordered.FindStringSubmatch(trimmed)
+			depth := len(m[1])
+			if m[1] == "-" {
+				depth = 1
+			}
+			out = append(out, strings.Repeat("  ", depth-1)+"- "+adocInline(m[2]))
+		case adocOrdered.MatchString(trimmed):
+			m := adocOrdered.FindStringSubmatch(trimmed)
+			out = append(out, strings.Repeat("   ", len(m[1])-1)+"1. "+adocInline(m[2]))
+		default:
+			out = append(out, adocInline(line))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// isRstAdornment reports if line is made of one punctuation character
+// repeated, the way rst over and underlines titles.
+func isRstAdornment(line string) bool {
+	line = strings.TrimSpace(line)
+	if len(line) < 2 || !strings.ContainsRune("=-`:'\"~^_*+#<>.", rune(line[0])) {
+		return false
+	}
+
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+func rstInline(line string) string {
+	line = rstLink.ReplaceAllString(line, "[$1]($2)")
+	line = rstCode.ReplaceAllString(line, "`$1`")
+
+	return line
+}
+
+func rstTitle(lines []string) string {
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		// The title is the first line that isn't an adornment
+		if trimmed != "" && !isRstAdornment(trimmed) && !rstDirective.MatchString(trimmed) {
+			return trimmed
+		}
+	}
+
+	return ""
+}
+
+func rstToMarkdown(content string) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+
+	// Heading levels follow the order the adornments first show up
+	levels := map[string]int{}
+	level := func(adornment string, overline bool) int {
+		key := adornment[:1]
+		if overline {
+			key += "o"
+		}
+		if _, ok := levels[key]; !ok {
+			levels[key] = len(levels) + 1
+		}
+		return levels[key]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		// Titles with both an overline and an underline
+		if isRstAdornment(trimmed) && i+2 < len(lines) && strings.TrimSpace(lines[i+2]) == trimmed && strings.TrimSpace(lines[i+1]) != "" {
+			out = append(out, strings.Repeat("#", level(trimmed, true))+" "+rstInline(strings.TrimSpace(lines[i+1])))
+			i += 2
+			continue
+		}
+		// Titles with just an underline
+		if trimmed != "" && !isRstAdornment(trimmed) && i+1 < len(lines) {
+			next := strings.TrimSpace(lines[i+1])
+			if isRstAdornment(next) && len([]rune(next)) >= len([]rune(trimmed)) {
+				out = append(out, strings.Repeat("#", level(next, false))+" "+rstInline(trimmed))
+				i++
+				continue
+			}
+		}
+
+		// Code blocks are the indented lines after the directive or ::
+		lang, isBlock := "", false
+		if m := rstCodeBlock.FindStringSubmatch(trimmed); m != nil {
+			lang, isBlock = m[1], true
+		} else if strings.HasSuffix(trimmed, "::") && !rstDirective.MatchString(trimmed) {
+			text := strings.TrimSuffix(trimmed, "::")
+			if text != "" {
+				out = append(out, rstInline(strings.TrimRight(line, ": ")+":"))
+			}
+			isBlock = true
+		}
+		if isBlock {
+			var block []string
+			j := i + 1
+			for ; j < len(lines); j++ {
+				next := lines[j]
+				if strings.TrimSpace(next) != "" && !strings.HasPrefix(next, " ") && !strings.HasPrefix(next, "\t") {
+					break
+				}
+				// Directive options like :linenos:
+				if len(block) == 0 && strings.HasPrefix(strings.TrimSpace(next), ":") {
+					continue
+				}
+				if len(block) == 0 && strings.TrimSpace(next) == "" {
+					continue
+				}
+				block = append(block, next)
+			}
+			for len(block) > 0 && strings.TrimSpace(block[len(block)-1]) == "" {
+				block = block[:len(block)-1]
+			}
+
+			out = append(out, "", "```"+lang)
+			out = append(out, dedent(block)...)
+			out = append(out, "```", "")
+			i = j - 1
+			continue
+		}
+
+		switch {
+		case rstImage.MatchString(trimmed):
+			out = append(out, "![]("+rstImage.FindStringSubmatch(trimmed)[1]+")")
+		case rstDirective.MatchString(trimmed):
+			// Comments and directives we don't know about
+		case rstOrdered.MatchString(line):
+			m := rstOrdered.FindStringSubmatch(line)
+			out = append(out, m[1]+"1. "+rstInline(m[2]))
+		case rstUnordered.MatchString(line):
+			m := rstUnordered.FindStringSubmatch(line)
+			out = append(out, m[1]+"- "+rstInline(m[2]))
+		default:
+			out = append(out, rstInline(line))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// dedent strips the indentation all the lines have in common
+func dedent(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		width := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || width < indent {
+			indent = width
+		}
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		out[i] = line
+	}
+
+	return out
+}
+
+// textToMarkdown keeps plain text looking the way it was written, the
+// line breaks are kept and nothing is taken as markup.
+func textToMarkdown(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		line = markdownChars.ReplaceAllString(line, `\$1`)
+		line = textListStart.ReplaceAllStringFunc(line, func(start string) string {
+			// 1. becomes 1\. while - becomes \-
+			m := textListStart.FindStringSubmatch(start)
+			if strings.HasSuffix(m[2], ".") {
+				return m[1] + strings.TrimSuffix(m[2], ".") + `\.` + m[3]
+			}
+			return m[1] + `\` + m[2] + m[3]
+		})
+		if strings.TrimSpace(line) != "" && i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			line += "\\"
+		}
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gekkowrld/go-gitconfig"
@@ -11,47 +11,52 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
-func commit(commitMsg string, filename string) {
+// commit stages filenames and commits them with commitMsg when git is
+// on. It returns the error rather than exiting as memo serve calls it
+// too.
+func commit(commitMsg string, filenames ...string) error {
 	isGitEnabled := getKeyValue("Git").(bool)
 	if !isGitEnabled {
-		return
+		return nil
 	}
 	// First move to the memoDirectory, then commit
 	memoDirectory := getKeyValue("MemoDir").(string)
 
+	if !DirectoryExists(memoDirectory) {
+		return fmt.Errorf("can't find %s, commit failed", memoDirectory)
+	}
+
 	// First check if the repo exists, init it if it doesn't exist
 	if !checkIfRepoExists() {
-		_, err := git.PlainInit(memoDirectory, false)
-		if err != nil {
-			log.Fatalf("Can't initialize %s", memoDirectory)
+		if _, err := git.PlainInit(memoDirectory, false); err != nil {
+			return fmt.Errorf("can't initialize %s: %v", memoDirectory, err)
 		}
 	}
 
-	// Panic if the directory isn't created yet
-	if !DirectoryExists(memoDirectory) {
-		log.Panicf("Can't find %s, commit failed", memoDirectory)
-	}
-
 	// Now stage the file
 	read, err := git.PlainOpen(memoDirectory)
-  if err != nil {
-    log.Panicf("%v open", err)
-  }
+	if err != nil {
+		return fmt.Errorf("can't open the repository: %v", err)
+	}
 	work, err := read.Worktree()
-  if err != nil {
-    log.Fatalf("%v read", err)
-  }
-  _, err = work.Add(filepath.Join(filename))
-  if err != nil {
-    log.Fatalf("%v add", err)
-  }
-  
-  stats, err := work.Status()
-  if err != nil {
-    log.Fatalf("%v stat", err)
-  }
-
-  fmt.Println(stats)
+	if err != nil {
+		return fmt.Errorf("can't read the worktree: %v", err)
+	}
+	for _, filename := range filenames {
+		// The worktree wants paths relative to its root
+		relName, err := filepath.Rel(memoDirectory, filename)
+		if err != nil {
+			return err
+		}
+		if FileExists(filename) || DirectoryExists(filename) {
+			_, err = work.Add(relName)
+		} else {
+			err = stageRemoval(work, relName)
+		}
+		if err != nil {
+			return fmt.Errorf("can't stage %s: %v", relName, err)
+		}
+	}
 
 	// Should now get the user git credentials
 	// Since the program can be run from anywhere, specify the starting location
@@ -59,25 +64,42 @@ func commit(commitMsg string, filename string) {
 	email, _ := gogitconfig.GetValue("user.email", memoDirectory)
 
 	// Commit the code
-	co, err := work.Commit(commitMsg, &git.CommitOptions{
+	_, err = work.Commit(commitMsg, &git.CommitOptions{
 		Author: &object.Signature{
 			Name:  username,
 			Email: email,
 			When:  time.Now(),
 		},
 	})
-
 	if err != nil {
-    log.Panicf("[%v]: Couldn't Commit", err)
+		return fmt.Errorf("couldn't commit: %v", err)
 	}
 
-	obj, err := read.CommitObject(co)
+	return nil
+}
+
+// stageRemoval stages the deletion of a file or of everything that
+// was in a removed directory, files that were never committed are skipped.
+func stageRemoval(work *git.Worktree, relName string) error {
+	stats, err := work.Status()
 	if err != nil {
-		log.Panic("Couldn't get ay info")
+		return err
 	}
 
-	fmt.Println(obj)
+	relName = filepath.ToSlash(relName)
+	for name, stat := range stats {
+		if name != relName && !strings.HasPrefix(name, relName+"/") {
+			continue
+		}
+		if stat.Worktree != git.Deleted {
+			continue
+		}
+		if _, err := work.Add(name); err != nil {
+			return err
+		}
+	}
 
+	return nil
 }
 
 func getGitValues(keyType string) string {
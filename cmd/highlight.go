@@ -0,0 +1,89 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Used when `highlight` isn't in the config, "none" turns highlighting
+// off
+const defaultHighlightStyle = "monokai"
+
+// Code blocks get classes, the colours of the style go in the style
+// sheet so the sanitiser has nothing to strip
+var codeFormatter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.PreventSurroundingPre(true), chromahtml.TabWidth(4))
+
+// highlightStyle is the chroma style named in the config, nil when code
+// blocks are left as they are
+func highlightStyle() (*chroma.Style, error) {
+	name := strings.ToLower(strings.TrimSpace(getKeyValue("Highlight").(string)))
+	switch name {
+	case "":
+		name = defaultHighlightStyle
+	case "none":
+		return nil, nil
+	}
+
+	style, ok := styles.Registry[name]
+	if !ok {
+		return styles.Get(defaultHighlightStyle), fmt.Errorf("unknown highlight style %q, use none or one of %s", name, strings.Join(styles.Names(), ", "))
+	}
+
+	return style, nil
+}
+
+// highlightCSS is the style sheet of the highlighted code blocks
+func highlightCSS() string {
+	style, _ := highlightStyle()
+	if style == nil {
+		return ""
+	}
+
+	var css bytes.Buffer
+	if err := codeFormatter.WriteCSS(&css, style); err != nil {
+		return ""
+	}
+
+	return css.String()
+}
+
+// highlightCode renders fenced code blocks in a language chroma knows,
+// the others are left to the markdown renderer
+func highlightCode(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	block, ok := node.(*ast.CodeBlock)
+	if !ok {
+		return ast.GoToNext, false
+	}
+
+	info := strings.Fields(string(block.Info))
+	if len(info) == 0 {
+		return ast.GoToNext, false
+	}
+	lexer := lexers.Get(info[0])
+	if lexer == nil {
+		return ast.GoToNext, false
+	}
+
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, string(block.Literal))
+	if err != nil {
+		return ast.GoToNext, false
+	}
+	var code bytes.Buffer
+	if err := codeFormatter.Format(&code, styles.Fallback, tokens); err != nil {
+		return ast.GoToNext, false
+	}
+
+	fmt.Fprintf(w, "<pre class=\"chroma\"><code>%s</code></pre>\n", code.String())
+	return ast.GoToNext, true
+}
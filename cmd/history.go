@@ -0,0 +1,609 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	textdiff "github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/spf13/cobra"
+)
+
+// How commits are dated in the history
+const historyLayout = "2006-01-02 15:04"
+
+var errNoHistory = errors.New("the notebook has no history, git is off in the config or nothing was committed yet")
+
+// memoRevision is a commit that changed a memo, Path is where the memo
+// was in it, relative to the notebook
+type memoRevision struct {
+	Commit *object.Commit
+	Path   string
+}
+
+var logCmd = &cobra.Command{
+	Use:   "log [memo]",
+	Short: "List the commits of the notebook or of a memo",
+	Long: `List the commits memo made in the notebook, newest first, or only the
+ones that changed a memo. The history of a memo follows it when it is
+moved.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		repo, err := notebookRepo()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var commits []*object.Commit
+		if len(args) == 0 {
+			iter, err := repo.Log(&git.LogOptions{})
+			if err != nil {
+				log.Fatal(errNoHistory)
+			}
+			iter.ForEach(func(c *object.Commit) error {
+				commits = append(commits, c)
+				return nil
+			})
+		} else {
+			_, history := historyOf(repo, args[0])
+			for _, rev := range history {
+				commits = append(commits, rev.Commit)
+			}
+		}
+
+		if limit > 0 && len(commits) > limit {
+			commits = commits[:limit]
+		}
+		printCommits(commits)
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <memo> [revision]",
+	Short: "Show the changes to a memo",
+	Long: `Show how a memo changed since a revision, a commit of memo log or ~N for
+N changes before the last one. Without a revision it shows the last
+change to the memo.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo, err := notebookRepo()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filename, history := historyOf(repo, args[0])
+		current, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var old []byte
+		switch {
+		case len(args) > 1:
+			rev, err := findRevision(repo, history, args[1])
+			if err != nil {
+				log.Fatal(err)
+			}
+			if old, err = revisionContent(rev); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			// Changes that weren't committed come first
+			for _, rev := range history {
+				content, err := revisionContent(rev)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if !bytes.Equal(content, current) {
+					old = content
+					break
+				}
+			}
+		}
+
+		if bytes.Equal(old, current) {
+			fmt.Println("No changes")
+			return
+		}
+
+		info, err := os.Stdout.Stat()
+		color := err == nil && info.Mode()&os.ModeCharDevice != 0
+		if err := writeMemoDiff(os.Stdout, history[0].Path, old, current, color); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <memo>@<revision>[#section]",
+	Short: "View a memo as it was in an earlier revision",
+	Long: `View a memo like memo view does, as it was in a revision: a commit of
+memo log or ~N for N changes before the last one.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		toc, _ := cmd.Flags().GetBool("toc")
+
+		ref, revName, ok := strings.Cut(args[0], "@")
+		if !ok || revName == "" {
+			log.Fatalf("[%s]: Give the revision as <memo>@<revision>, memo log lists them", args[0])
+		}
+		revName, section, _ := strings.Cut(revName, "#")
+
+		repo, err := notebookRepo()
+		if err != nil {
+			log.Fatal(err)
+		}
+		filename, history := historyOf(repo, ref)
+		rev, err := findRevision(repo, history, revName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		content, err := revisionContent(rev)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// displayMemo reads a file, the name tells the format
+		dir, err := os.MkdirTemp("", "memo-show")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		old := filepath.Join(dir, filepath.Base(filename))
+		if err := os.WriteFile(old, content, 0600); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Println(lipgloss.NewStyle().Foreground(lipgloss.Color("245")).Render(fmt.Sprintf("%s  %s  %s",
+			rev.Commit.Hash.String()[:7], rev.Commit.Author.When.Local().Format(historyLayout), commitSubject(rev.Commit))))
+		displayMemo(old, section, toc)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(showCmd)
+
+	logCmd.Flags().IntP("limit", "l", 0, "Only list this many commits")
+	showCmd.Flags().Bool("toc", false, "Show the table of contents before the memo")
+}
+
+func notebookRepo() (*git.Repository, error) {
+	repo, err := git.PlainOpen(getKeyValue("MemoDir").(string))
+	if err != nil {
+		return nil, errNoHistory
+	}
+
+	return repo, nil
+}
+
+// historyOf finds the memo ref names and its history, there is at least
+// one revision
+func historyOf(repo *git.Repository, ref string) (string, []memoRevision) {
+	filename := matchMemo(ref)
+	if filename == "" {
+		log.Fatalf("[%s]: Couldn't match any memo", ref)
+	}
+
+	rel, err := filepath.Rel(getKeyValue("MemoDir").(string), filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	history, err := memoHistory(repo, filepath.ToSlash(rel))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(history) == 0 {
+		log.Fatalf("[%s]: The memo isn't committed", ref)
+	}
+
+	return filename, history
+}
+
+// historyCache keeps the histories worked out since the last commit,
+// memo serve asks for the same ones on every page of a memo
+var historyCache = struct {
+	sync.Mutex
+	head  plumbing.Hash
+	memos map[string][]memoRevision
+}{}
+
+// memoHistory follows the memo at path back through the first parents
+// of HEAD, across moves, up to the commit that added it. The newest
+// revision comes first.
+func memoHistory(repo *git.Repository, path string) ([]memoRevision, error) {
+	head, err := repo.Head()
+	if err != nil {
+		// No commits yet
+		return nil, nil
+	}
+
+	historyCache.Lock()
+	defer historyCache.Unlock()
+	if historyCache.head != head.Hash() {
+		historyCache.head = head.Hash()
+		historyCache.memos = map[string][]memoRevision{}
+	}
+	if history, ok := historyCache.memos[path]; ok {
+		return history, nil
+	}
+
+	c, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	history, err := followMemo(c, path)
+	if err != nil {
+		return nil, err
+	}
+	historyCache.memos[path] = history
+
+	return history, nil
+}
+
+func followMemo(c *object.Commit, path string) ([]memoRevision, error) {
+	var history []memoRevision
+	for c != nil {
+		tree, err := c.Tree()
+		if err != nil {
+			return nil, err
+		}
+		entry, err := tree.FindEntry(path)
+		if err != nil {
+			// Not in this commit, a memo that was never committed
+			return history, nil
+		}
+		if c.NumParents() == 0 {
+			return append(history, memoRevision{Commit: c, Path: path}), nil
+		}
+
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		if old, err := parentTree.FindEntry(path); err == nil {
+			if old.Hash != entry.Hash {
+				history = append(history, memoRevision{Commit: c, Path: path})
+			}
+			c = parent
+			continue
+		}
+
+		// Added or moved here, only telling them apart needs the
+		// whole diff
+		history = append(history, memoRevision{Commit: c, Path: path})
+		changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, &object.DiffTreeOptions{DetectRenames: true})
+		if err != nil {
+			return nil, err
+		}
+		from := ""
+		for _, change := range changes {
+			if change.To.Name == path {
+				from = change.From.Name
+				break
+			}
+		}
+		if from == "" {
+			// Anything older is another memo
+			return history, nil
+		}
+		path = from
+		c = parent
+	}
+
+	return history, nil
+}
+
+// findRevision picks the revision rev names: a commit (a hash, a prefix
+// of one or anything git understands) or ~N for N changes before the
+// newest one
+func findRevision(repo *git.Repository, history []memoRevision, rev string) (memoRevision, error) {
+	if back, ok := strings.CutPrefix(rev, "~"); ok {
+		n, err := strconv.Atoi(back)
+		if err != nil || n < 0 {
+			return memoRevision{}, fmt.Errorf("[%s]: ~ wants a number of changes, like ~1", rev)
+		}
+		if n >= len(history) {
+			return memoRevision{}, fmt.Errorf("[%s]: The memo only changed %d times", rev, len(history))
+		}
+		return history[n], nil
+	}
+
+	if len(rev) >= 4 {
+		for _, r := range history {
+			if strings.HasPrefix(r.Commit.Hash.String(), strings.ToLower(rev)) {
+				return r, nil
+			}
+		}
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return memoRevision{}, fmt.Errorf("[%s]: Couldn't find the revision, memo log lists them", rev)
+	}
+	c, err := repo.CommitObject(*hash)
+	if err != nil {
+		return memoRevision{}, err
+	}
+	// The memo is as its newest change before the commit left it
+	for _, r := range history {
+		if !r.Commit.Committer.When.After(c.Committer.When) {
+			return memoRevision{Commit: c, Path: r.Path}, nil
+		}
+	}
+
+	return memoRevision{}, fmt.Errorf("[%s]: The memo didn't exist yet", rev)
+}
+
+func revisionContent(rev memoRevision) ([]byte, error) {
+	file, err := rev.Commit.File(rev.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s: %v", rev.Path, rev.Commit.Hash.String()[:7], err)
+	}
+	content, err := file.Contents()
+
+	return []byte(content), err
+}
+
+// commitSubject is the first line of the message of c
+func commitSubject(c *object.Commit) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return subject
+}
+
+func printCommits(commits []*object.Commit) {
+	hashStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#B5A45C"))
+	dateStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	kindStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("99"))
+
+	for _, c := range commits {
+		subject := commitSubject(c)
+		// The [New]: of memo's messages stands out
+		if strings.HasPrefix(subject, "[") {
+			if end := strings.Index(subject, "]:"); end > 0 {
+				subject = kindStyle.Render(subject[:end+2]) + subject[end+2:]
+			}
+		}
+		fmt.Printf("%s  %s  %s\n", hashStyle.Render(c.Hash.String()[:7]), dateStyle.Render(c.Author.When.Local().Format(historyLayout)), subject)
+	}
+}
+
+// The diff of a memo, as go-git's unified encoder wants it
+type memoPatch struct {
+	from, to diff.File
+	chunks   []diff.Chunk
+}
+
+func (p memoPatch) FilePatches() []diff.FilePatch { return []diff.FilePatch{p} }
+func (p memoPatch) Message() string               { return "" }
+func (p memoPatch) IsBinary() bool                { return false }
+func (p memoPatch) Files() (diff.File, diff.File) { return p.from, p.to }
+func (p memoPatch) Chunks() []diff.Chunk          { return p.chunks }
+
+type memoVersion struct {
+	path string
+	hash plumbing.Hash
+}
+
+func (v memoVersion) Hash() plumbing.Hash     { return v.hash }
+func (v memoVersion) Mode() filemode.FileMode { return filemode.Regular }
+func (v memoVersion) Path() string            { return v.path }
+
+type memoChunk struct {
+	content string
+	op      diff.Operation
+}
+
+func (c memoChunk) Content() string      { return c.content }
+func (c memoChunk) Type() diff.Operation { return c.op }
+
+var chunkOps = map[diffmatchpatch.Operation]diff.Operation{
+	diffmatchpatch.DiffEqual:  diff.Equal,
+	diffmatchpatch.DiffInsert: diff.Add,
+	diffmatchpatch.DiffDelete: diff.Delete,
+}
+
+// writeMemoDiff writes the changes from old to current of the memo at
+// path as a unified diff, old is nil when the memo is new
+func writeMemoDiff(w io.Writer, path string, old []byte, current []byte, color bool) error {
+	patch := memoPatch{to: memoVersion{path: path, hash: plumbing.ComputeHash(plumbing.BlobObject, current)}}
+	if old != nil {
+		patch.from = memoVersion{path: path, hash: plumbing.ComputeHash(plumbing.BlobObject, old)}
+	}
+	for _, d := range textdiff.Do(string(old), string(current)) {
+		patch.chunks = append(patch.chunks, memoChunk{content: d.Text, op: chunkOps[d.Type]})
+	}
+
+	encoder := diff.NewUnifiedEncoder(w, diff.DefaultContextLines)
+	if color {
+		encoder.SetColor(diff.NewColorConfig())
+	}
+
+	return encoder.Encode(patch)
+}
+
+// diffHTML colours the lines of a unified diff
+func diffHTML(unified string) template.HTML {
+	var out strings.Builder
+	hunks := false
+	for _, line := range strings.SplitAfter(unified, "\n") {
+		if line == "" {
+			continue
+		}
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			class, hunks = "diff-hunk", true
+		case !hunks:
+			class = "diff-head"
+		case strings.HasPrefix(line, "+"):
+			class = "diff-add"
+		case strings.HasPrefix(line, "-"):
+			class = "diff-del"
+		}
+		if class == "" {
+			out.WriteString(template.HTMLEscapeString(line))
+			continue
+		}
+		fmt.Fprintf(&out, "<span class=\"%s\">%s</span>", class, template.HTMLEscapeString(line))
+	}
+
+	return template.HTML("<pre class=\"diff\">" + out.String() + "</pre>")
+}
+
+// historyLink leads to the history page of a memo, nothing when git is off
+func historyLink(memo memoFile) string {
+	if !getKeyValue("Git").(bool) {
+		return ""
+	}
+
+	return fmt.Sprintf("<a href=\"/history/%d\">History</a>", memo.Number)
+}
+
+type historyEntry struct {
+	URL     string
+	Hash    string
+	Date    string
+	Subject string
+	Path    string
+}
+
+type historyData struct {
+	Memo    string
+	Entries []historyEntry
+}
+
+var historyTemplate = template.Must(template.New("history").Parse(`<p>The changes to <a href="{{ .Memo }}">this memo</a>, newest first.</p>
+<ol class="history">
+{{ range .Entries }}<li><a href="{{ .URL }}"><code>{{ .Hash }}</code></a> <time>{{ .Date }}</time> {{ .Subject }}{{ with .Path }} <span class="history-path">{{ . }}</span>{{ end }}</li>
+{{ end }}</ol>`))
+
+// historyPage lists the changes to a memo on /history/<number> and
+// shows one of them on /history/<number>/<commit>
+func historyPage(w http.ResponseWriter, r *http.Request) {
+	memo, hash, ok := memoFromPath(r.URL.Path, "/history/")
+	if !ok {
+		displayCustom404(w, r)
+		return
+	}
+
+	var history []memoRevision
+	rel, err := filepath.Rel(getKeyValue("MemoDir").(string), memo.Path)
+	repo, repoErr := notebookRepo()
+	if err == nil && repoErr == nil {
+		history, err = memoHistory(repo, filepath.ToSlash(rel))
+	}
+	if err != nil {
+		log.Print(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	title := memoTitle(memo.Path)
+	if hash == "" {
+		data := historyData{Memo: memoURL(memo)}
+		for _, rev := range history {
+			entry := historyEntry{
+				URL:     fmt.Sprintf("/history/%d/%s", memo.Number, rev.Commit.Hash),
+				Hash:    rev.Commit.Hash.String()[:7],
+				Date:    rev.Commit.Author.When.Local().Format(historyLayout),
+				Subject: commitSubject(rev.Commit),
+			}
+			if rev.Path != filepath.ToSlash(rel) {
+				entry.Path = rev.Path
+			}
+			data.Entries = append(data.Entries, entry)
+		}
+
+		main := template.HTML("<p>This memo has no history yet.</p>")
+		if len(data.Entries) > 0 {
+			var out bytes.Buffer
+			if err := historyTemplate.Execute(&out, data); err != nil {
+				log.Print(err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			main = template.HTML(out.String())
+		}
+
+		renderPage(w, r, inputData{
+			Title:       "History of " + title,
+			Breadcrumbs: breadcrumbs(memo.Folder),
+			Main:        main,
+		})
+		return
+	}
+
+	for i, rev := range history {
+		if rev.Commit.Hash.String() != hash {
+			continue
+		}
+
+		content, err := revisionContent(rev)
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		// What the commit changed
+		var before []byte
+		if i+1 < len(history) {
+			if before, err = revisionContent(history[i+1]); err != nil {
+				log.Print(err)
+			}
+		}
+		var changes bytes.Buffer
+		if err := writeMemoDiff(&changes, rev.Path, before, content, false); err != nil {
+			log.Print(err)
+		}
+
+		userHTML := fmt.Sprintf("<p class=\"history-note\">This is the memo as it was on %s after <code>%s</code> %s. See <a href=\"%s\">the current version</a> or <a href=\"/history/%d\">every change</a>.</p>",
+			rev.Commit.Author.When.Local().Format(historyLayout), rev.Commit.Hash.String()[:7], template.HTMLEscapeString(commitSubject(rev.Commit)), memoURL(memo), memo.Number)
+		userHTML += "<details class=\"history-diff\"><summary>Changes in this version</summary>" + string(diffHTML(changes.String())) + "</details>"
+		// The memo may have been in another format then
+		old := memo
+		old.Path = filepath.Join(getKeyValue("MemoDir").(string), filepath.FromSlash(rev.Path))
+		userHTML += memoHTML(old, content, fmt.Sprintf("/attachments/%d/", memo.Number), func(tag string) string {
+			return "/tag/" + url.PathEscape(tag)
+		})
+
+		renderPage(w, r, inputData{
+			Title:       title + " @ " + rev.Commit.Hash.String()[:7],
+			Breadcrumbs: breadcrumbs(memo.Folder),
+			Main:        template.HTML(userHTML),
+			TOC:         tocHTML(memoMarkdown(old.Path, content)),
+		})
+		return
+	}
+
+	displayCustom404(w, r)
+}
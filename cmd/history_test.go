@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// historyStep is a commit, files maps the paths it writes to their
+// content and moves the paths it renames to their new one
+type historyStep struct {
+	message string
+	files   map[string]string
+	moves   map[string]string
+	removes []string
+}
+
+func TestFollowMemo(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []historyStep
+		path  string
+		// message and path of each revision, the newest first
+		want []string
+	}{
+		{"edited", []historyStep{
+			{message: "add", files: map[string]string{"a.md": "one"}},
+			{message: "other memo", files: map[string]string{"b.md": "b"}},
+			{message: "edit", files: map[string]string{"a.md": "two"}},
+		}, "a.md", []string{"edit a.md", "add a.md"}},
+		{"moved", []historyStep{
+			{message: "add", files: map[string]string{"a.md": "some text long enough to be found again"}},
+			{message: "move", moves: map[string]string{"a.md": "work/a.md"}},
+			{message: "edit", files: map[string]string{"work/a.md": "some text long enough to be found again, and more"}},
+		}, "work/a.md", []string{"edit work/a.md", "move work/a.md", "add a.md"}},
+		// A memo deleted and written again is another memo
+		{"recreated", []historyStep{
+			{message: "add", files: map[string]string{"a.md": "old"}},
+			{message: "delete", removes: []string{"a.md"}},
+			{message: "add again", files: map[string]string{"a.md": "new"}},
+		}, "a.md", []string{"add again a.md"}},
+		{"nothing changed", []historyStep{
+			{message: "add", files: map[string]string{"a.md": "one"}},
+			{message: "empty"},
+		}, "a.md", []string{"add a.md"}},
+		{"never committed", []historyStep{
+			{message: "add", files: map[string]string{"a.md": "one"}},
+		}, "b.md", nil},
+		{"deleted", []historyStep{
+			{message: "add", files: map[string]string{"a.md": "one"}},
+			{message: "delete", removes: []string{"a.md"}},
+		}, "a.md", nil},
+	}
+
+	for _, test := range tests {
+		head := commitSteps(t, test.steps)
+
+		history, err := followMemo(head, test.path)
+		if err != nil {
+			t.Errorf("%s: followMemo: %v", test.name, err)
+			continue
+		}
+
+		var got []string
+		for _, rev := range history {
+			got = append(got, rev.Commit.Message+" "+rev.Path)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: followMemo = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// commitSteps makes a repository with a commit for each step and
+// returns the last one
+func commitSteps(t *testing.T, steps []historyStep) *object.Commit {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	when := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, step := range steps {
+		for path, content := range step.files {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := worktree.Add(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for from, to := range step.moves {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(to)), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := worktree.Move(from, to); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, path := range step.removes {
+			if _, err := worktree.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		when = when.Add(time.Hour)
+		signature := &object.Signature{Name: "memo", Email: "memo@localhost", When: when}
+		if _, err := worktree.Commit(step.message, &git.CommitOptions{Author: signature, AllowEmptyCommits: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
@@ -0,0 +1,268 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// Layouts of the dates and times of iCalendar (RFC 5545), times are
+// written in UTC so no time zones have to be described
+const (
+	icsDate = "20060102"
+	icsTime = "20060102T150405Z"
+)
+
+// Minutes a reminder takes in the calendar
+const icsReminderLength = 15 * time.Minute
+
+// Priorities of tasks as iCalendar numbers them, 1 is the highest
+var icsPriorities = []int{0, 1, 5, 9}
+
+var exportICSCmd = &cobra.Command{
+	Use:   "ics",
+	Short: "Export the memos, reminders and due tasks as an iCalendar file",
+	Long: `Write an .ics file calendar apps can import: every memo is an all day
+event on the day it was written, reminders are events with an alarm and
+tasks with a due date are todos`,
+	Run: func(cmd *cobra.Command, args []string) {
+		out, _ := cmd.Flags().GetString("out")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		base := getKeyValue("SiteURL").(string)
+		if cmd.Flag("base-url").Changed {
+			base, _ = cmd.Flags().GetString("base-url")
+		}
+
+		memos := exportedMemos(getKeyValue("MemoDir").(string), tags)
+		if len(memos) == 0 {
+			log.Fatal("No memos to export")
+		}
+
+		var link func(memo memoFile) string
+		if base = strings.TrimRight(base, "/"); base != "" {
+			link = func(memo memoFile) string { return base + memoURL(memo) }
+		}
+
+		w := io.Writer(os.Stdout)
+		if out != "" && out != "-" {
+			file, err := os.Create(out)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer file.Close()
+			w = file
+		}
+
+		if err := writeICS(w, calendarName(tags), memos, link); err != nil {
+			log.Fatalf("Couldn't export the memos: %v", err)
+		}
+		if w != io.Writer(os.Stdout) {
+			fmt.Printf("Exported %d memos to %s\n", len(memos), out)
+		}
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportICSCmd)
+
+	exportICSCmd.Flags().StringP("out", "o", "", "File to write the calendar to (default the standard output)")
+	exportICSCmd.Flags().StringSliceP("tag", "t", nil, "Only export the memos with one of these tags")
+	exportICSCmd.Flags().String("base-url", "", "Address memo serve is reached at, for links to the memos (default siteurl in the config)")
+}
+
+// calendarName is the name calendar apps show for the memos with tags
+func calendarName(tags []string) string {
+	if len(tags) == 0 {
+		return "Memo"
+	}
+
+	return "Memo #" + strings.Join(tags, " #")
+}
+
+// icsWriter writes the content lines of a calendar, folded at 75
+// octets, keeping the first error
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (c *icsWriter) line(name string, value string) {
+	if c.err != nil {
+		return
+	}
+
+	line := name + ":" + value
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, c.err = c.w.WriteString(line[:cut] + "\r\n "); c.err != nil {
+			return
+		}
+		line = line[cut:]
+		// The space starting the next line counts
+		limit = 74
+	}
+	_, c.err = c.w.WriteString(line + "\r\n")
+}
+
+// icsText escapes text for a TEXT value
+func icsText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// icsUID names an event or a todo so calendar apps update it rather
+// than add it again
+func icsUID(kind string, memo memoFile, key string) string {
+	if key == "" {
+		return fmt.Sprintf("%s-%d@memo", kind, memo.Number)
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s-%d-%x@memo", kind, memo.Number, sum[:6])
+}
+
+// writeICS writes memos as a calendar named name, link gives the
+// address of a memo and is nil when there is none
+func writeICS(w io.Writer, name string, memos []memoFile, link func(memo memoFile) string) error {
+	c := &icsWriter{w: bufio.NewWriter(w)}
+	stamp := time.Now().UTC().Format(icsTime)
+
+	c.line("BEGIN", "VCALENDAR")
+	c.line("VERSION", "2.0")
+	c.line("PRODID", "-//memo//memo//EN")
+	c.line("CALSCALE", "GREGORIAN")
+	c.line("METHOD", "PUBLISH")
+	c.line("X-WR-CALNAME", icsText(name))
+
+	for _, memo := range memos {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(memo.Path)
+		if err != nil {
+			continue
+		}
+		fm, _ := splitFrontMatter(string(content))
+		title := strings.TrimSpace(memoTitle(memo.Path))
+		where := fmt.Sprintf("memo view %d", memo.Number)
+		url := ""
+		if link != nil {
+			url = link(memo)
+			where = url
+		}
+
+		date := memoDate(memo, fm)
+		c.line("BEGIN", "VEVENT")
+		c.line("UID", icsUID("memo", memo, ""))
+		c.line("DTSTAMP", stamp)
+		c.line("DTSTART;VALUE=DATE", date.Format(icsDate))
+		c.line("DTEND;VALUE=DATE", date.AddDate(0, 0, 1).Format(icsDate))
+		c.line("LAST-MODIFIED", info.ModTime().UTC().Format(icsTime))
+		c.line("SUMMARY", icsText(title))
+		c.line("DESCRIPTION", icsText(strings.TrimSpace(memoExcerpt(string(memoMarkdown(memo.Path, content)), indexExcerptWidth)+"\n\n"+where)))
+		if tags := memoTags(string(content)); len(tags) > 0 {
+			for i, tag := range tags {
+				tags[i] = icsText(tag)
+			}
+			c.line("CATEGORIES", strings.Join(tags, ","))
+		}
+		if url != "" {
+			c.line("URL", url)
+		}
+		c.line("TRANSP", "TRANSPARENT")
+		c.line("END", "VEVENT")
+
+		for _, r := range memoReminders(memo, content) {
+			// Due tasks are written as todos below
+			if r.Line > 0 {
+				continue
+			}
+			c.line("BEGIN", "VEVENT")
+			c.line("UID", icsUID("remind", memo, r.At.UTC().Format(icsTime)))
+			c.line("DTSTAMP", stamp)
+			c.line("DTSTART", r.At.UTC().Format(icsTime))
+			c.line("DTEND", r.At.Add(icsReminderLength).UTC().Format(icsTime))
+			c.line("SUMMARY", icsText(title))
+			c.line("DESCRIPTION", icsText(where))
+			if url != "" {
+				c.line("URL", url)
+			}
+			c.line("BEGIN", "VALARM")
+			c.line("ACTION", "DISPLAY")
+			c.line("DESCRIPTION", icsText(title))
+			c.line("TRIGGER", "PT0M")
+			c.line("END", "VALARM")
+			c.line("END", "VEVENT")
+		}
+
+		// The same task can be written twice, the count tells them apart
+		// and unlike the line it stays when something is added above
+		seen := map[string]int{}
+		for _, task := range readTasks(memo, content) {
+			if task.Due.IsZero() {
+				continue
+			}
+			seen[task.Text]++
+			key := task.Text
+			if seen[task.Text] > 1 {
+				key = fmt.Sprintf("%s\x00%d", task.Text, seen[task.Text])
+			}
+			c.line("BEGIN", "VTODO")
+			c.line("UID", icsUID("task", memo, key))
+			c.line("DTSTAMP", stamp)
+			c.line("DUE;VALUE=DATE", task.Due.Format(icsDate))
+			c.line("SUMMARY", icsText(task.Text))
+			c.line("DESCRIPTION", icsText(fmt.Sprintf("%s\n\nmemo todo done %d:%d", title, memo.Number, task.Line)))
+			if task.Priority > 0 {
+				c.line("PRIORITY", fmt.Sprint(icsPriorities[task.Priority]))
+			}
+			if task.Done {
+				c.line("STATUS", "COMPLETED")
+			} else {
+				c.line("STATUS", "NEEDS-ACTION")
+			}
+			if url != "" {
+				c.line("URL", url)
+			}
+			c.line("END", "VTODO")
+		}
+	}
+
+	c.line("END", "VCALENDAR")
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.w.Flush()
+}
+
+// serveICS serves /calendar.ics, ?tag= keeps the memos with that tag
+func serveICS(w http.ResponseWriter, r *http.Request) {
+	var tags []string
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		tags = append(tags, tag)
+	}
+	memos := exportedMemos(getKeyValue("MemoDir").(string), tags)
+
+	site := siteURL(r)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err := writeICS(w, calendarName(tags), memos, func(memo memoFile) string { return site + memoURL(memo) }); err != nil {
+		log.Print(err)
+	}
+}
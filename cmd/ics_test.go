@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestICSText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Plain", "Plain"},
+		{"a, b; c", `a\, b\; c`},
+		{`C:\memo`, `C:\\memo`},
+		{"two\nlines", `two\nlines`},
+		{"windows\r\nlines", `windows\nlines`},
+		{`\,`, `\\\,`},
+	}
+
+	for _, test := range tests {
+		if got := icsText(test.text); got != test.want {
+			t.Errorf("icsText(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestICSLineFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		lines int
+	}{
+		{"short", "Hello", 1},
+		{"exactly 75", strings.Repeat("a", 75-len("SUMMARY:")), 1},
+		{"one over", strings.Repeat("a", 76-len("SUMMARY:")), 2},
+		{"long", strings.Repeat("abcdefghij", 30), 5},
+		// Multi-byte characters aren't cut in half
+		{"accents", strings.Repeat("é", 100), 3},
+		{"emoji", strings.Repeat("🗒", 60), 4},
+	}
+
+	for _, test := range tests {
+		var out strings.Builder
+		w := bufio.NewWriter(&out)
+		c := &icsWriter{w: w}
+		c.line("SUMMARY", test.value)
+		w.Flush()
+
+		text := out.String()
+		if !strings.HasSuffix(text, "\r\n") {
+			t.Errorf("%s: %q doesn't end with CRLF", test.name, text)
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(text, "\r\n"), "\r\n")
+		if len(lines) != test.lines {
+			t.Errorf("%s: folded into %d lines, want %d", test.name, len(lines), test.lines)
+		}
+		for i, line := range lines {
+			if len(line) > 75 {
+				t.Errorf("%s: line %d is %d octets long", test.name, i, len(line))
+			}
+			if i > 0 && !strings.HasPrefix(line, " ") {
+				t.Errorf("%s: line %d doesn't start with a space", test.name, i)
+			}
+			if !utf8.ValidString(line) {
+				t.Errorf("%s: line %d cuts a character in half", test.name, i)
+			}
+		}
+
+		// Unfolding gives the line back
+		if unfolded := strings.ReplaceAll(strings.TrimSuffix(text, "\r\n"), "\r\n ", ""); unfolded != "SUMMARY:"+test.value {
+			t.Errorf("%s: unfolds to %q", test.name, unfolded)
+		}
+	}
+}
+
+// Two tasks written the same way are two todos
+func TestWriteICSTaskUIDs(t *testing.T) {
+	memoDir := useConfig(t, "")
+	path := filepath.Join(memoDir, "1-2026-10-14-rent.md")
+	content := "# Rent\n\n- [ ] Pay rent @due(2026-11-01)\n- [ ] Pay rent @due(2026-11-01)\n- [ ] Pay rent @due(2026-12-01)\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := writeICS(&out, "Memo", readMemos(memoDir), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	uids := map[string]bool{}
+	for _, line := range strings.Split(out.String(), "\r\n") {
+		if uid, ok := strings.CutPrefix(line, "UID:task-"); ok {
+			if uids[uid] {
+				t.Errorf("UID task-%s is used twice", uid)
+			}
+			uids[uid] = true
+		}
+	}
+	if len(uids) != 3 {
+		t.Errorf("the calendar has %d todos, want 3:\n%s", len(uids), out.String())
+	}
+
+	// The first one keeps the UID it had before the count
+	if want := icsUID("task", memoFile{memoName: memoName{Number: 1}}, "Pay rent"); !strings.Contains(out.String(), "UID:"+want+"\r\n") {
+		t.Errorf("the first task isn't %s", want)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Memos shown on one page of the index
+const indexPageSize = 25
+
+// Length of the excerpt under every memo of the index
+const indexExcerptWidth = 200
+
+// indexSorts are the orders the index offers, the first one is the
+// default
+var indexSorts = []struct {
+	value string
+	label string
+}{
+	{"number", "Number"},
+	{"-created", "Newest"},
+	{"created", "Oldest"},
+	{"-modified", "Recently changed"},
+	{"title", "Title"},
+}
+
+// indexEntry is a memo as the index shows it
+type indexEntry struct {
+	memo     memoFile
+	URL      string
+	Title    string
+	Number   int
+	Created  time.Time
+	Modified time.Time
+	Tags     []indexLink
+	Excerpt  string
+}
+
+type indexLink struct {
+	URL     string
+	Label   string
+	Current bool
+}
+
+type indexData struct {
+	Sorts   []indexLink
+	Folders []indexLink
+	Memos   []indexEntry
+	Pager   template.HTML
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"day": func(t time.Time) string { return t.Format("2006-01-02") },
+}).Parse(`{{ if and .Sorts (gt (len .Memos) 1) }}<nav class="index-sort">Sort by{{ range .Sorts }} {{ if .Current }}<strong>{{ .Label }}</strong>{{ else }}<a href="{{ .URL }}">{{ .Label }}</a>{{ end }}{{ end }}</nav>{{ end }}
+{{ range .Folders }}<a class="main-link folder-link" href="{{ .URL }}">{{ .Label }}/</a><br/>
+{{ end }}{{ range .Memos }}<article class="index-memo">
+<a class="main-link" href="{{ .URL }}">{{ .Title }}</a> <span class="result-meta">#{{ .Number }}
+{{- if not .Created.IsZero }} · created <time datetime="{{ day .Created }}">{{ day .Created }}</time>{{ end }}
+{{- if not .Modified.IsZero }} · changed <time datetime="{{ day .Modified }}">{{ day .Modified }}</time>{{ end }}</span>
+{{ with .Tags }}<p class="index-tags">{{ range . }}<a href="{{ .URL }}">#{{ .Label }}</a> {{ end }}</p>{{ end }}
+{{ with .Excerpt }}<p class="snippet">{{ . }}</p>{{ end }}
+</article>
+{{ else }}{{ if not .Folders }}<p>No memos here yet.</p>{{ end }}{{ end }}{{ .Pager }}`))
+
+// memoIndex lists folders and memos one page at a time, in the order
+// asked for by ?sort= and at the page in ?page=. base is the escaped
+// path of the page the list is on.
+func memoIndex(r *http.Request, base string, folders []indexLink, memos []memoFile) (template.HTML, error) {
+	params := r.URL.Query()
+	order := params.Get("sort")
+	page, err := strconv.Atoi(params.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	entries := indexEntries(memos)
+	order = sortIndex(entries, order)
+
+	start := min((page-1)*indexPageSize, len(entries))
+	end := min(start+indexPageSize, len(entries))
+	data := indexData{
+		Memos: entries[start:end],
+		Pager: template.HTML(pager(base, page, len(entries), indexPageSize, params)),
+	}
+	// Folders only sit on top of the first page
+	if page == 1 {
+		data.Folders = folders
+	}
+
+	for _, s := range indexSorts {
+		next := url.Values{}
+		if s.value != indexSorts[0].value {
+			next.Set("sort", s.value)
+		}
+		link := base
+		if len(next) > 0 {
+			link += "?" + next.Encode()
+		}
+		data.Sorts = append(data.Sorts, indexLink{URL: link, Label: s.label, Current: s.value == order})
+	}
+
+	// Only the memos on this page need an excerpt
+	addExcerpts(data.Memos)
+
+	return renderIndex(data)
+}
+
+// renderIndex fills the index template with data
+func renderIndex(data indexData) (template.HTML, error) {
+	var out bytes.Buffer
+	if err := indexTemplate.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return template.HTML(out.String()), nil
+}
+
+// indexEntries reads what the index shows about every memo, memos that
+// can't be read are left out rather than failing the whole page
+func indexEntries(memos []memoFile) []indexEntry {
+	var entries []indexEntry
+	for _, memo := range memos {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			log.Printf("Leaving %s out of the index: %v", memo.Path, err)
+			continue
+		}
+		info, err := os.Stat(memo.Path)
+		if err != nil {
+			continue
+		}
+		fm, _ := splitFrontMatter(string(content))
+
+		entry := indexEntry{
+			memo:     memo,
+			URL:      memoURL(memo),
+			Title:    strings.TrimSpace(memoTitle(memo.Path)),
+			Number:   memo.Number,
+			Created:  memoDate(memo, fm),
+			Modified: info.ModTime(),
+		}
+		for _, tag := range memoTags(string(content)) {
+			entry.Tags = append(entry.Tags, indexLink{URL: "/tag/" + url.PathEscape(tag), Label: tag})
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func addExcerpts(entries []indexEntry) {
+	for i := range entries {
+		entry := &entries[i]
+		if content, err := os.ReadFile(entry.memo.Path); err == nil {
+			entry.Excerpt = memoExcerpt(string(memoMarkdown(entry.memo.Path, content)), indexExcerptWidth)
+		}
+	}
+}
+
+// memoSortKey is what memos are sorted on
+type memoSortKey struct {
+	Number   int
+	Created  time.Time
+	Modified time.Time
+	Title    string
+}
+
+// sortMemos sorts items by number, created, modified or title, reversed
+// with a leading "-", key tells what an item is sorted on. It reports
+// false and leaves items alone when the order is unknown.
+func sortMemos[T any](items []T, order string, key func(T) memoSortKey) bool {
+	var less func(a, b memoSortKey) bool
+	switch strings.TrimPrefix(order, "-") {
+	case "number", "id":
+		less = func(a, b memoSortKey) bool { return a.Number < b.Number }
+	case "created":
+		less = func(a, b memoSortKey) bool { return a.Created.Before(b.Created) }
+	case "modified":
+		less = func(a, b memoSortKey) bool { return a.Modified.Before(b.Modified) }
+	case "title":
+		less = func(a, b memoSortKey) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	default:
+		return false
+	}
+
+	descending := strings.HasPrefix(order, "-")
+	sort.SliceStable(items, func(i, j int) bool {
+		if descending {
+			return less(key(items[j]), key(items[i]))
+		}
+		return less(key(items[i]), key(items[j]))
+	})
+
+	return true
+}
+
+// sortIndex sorts the entries by number, created, modified or title,
+// reversed with a leading "-". An unknown order falls back to the
+// default, the order used is returned.
+func sortIndex(entries []indexEntry, order string) string {
+	key := func(e indexEntry) memoSortKey {
+		return memoSortKey{Number: e.Number, Created: e.Created, Modified: e.Modified, Title: e.Title}
+	}
+	if !sortMemos(entries, order, key) {
+		order = indexSorts[0].value
+		sortMemos(entries, order, key)
+	}
+
+	return order
+}
@@ -6,9 +6,7 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -18,64 +16,149 @@ import (
 
 // listCmd represents the list command
 var listCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [folder]",
 	Short: "List the memos already created",
-	Long:  `List the memos that you have already crated in a list form`,
+	Long:  `List the memos that you have already crated in a list form, optionally only the ones in a folder`,
 	Run: func(cmd *cobra.Command, args []string) {
-		List()
+		all, _ := cmd.Flags().GetBool("all")
+		tree, _ := cmd.Flags().GetBool("tree")
+
+		var folder string
+		if len(args) > 0 {
+			var err error
+			folder, err = cleanFolder(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if all {
+			ListAll(folder, tree)
+		} else {
+			List(folder, tree)
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolP("all", "a", false, "List the memos in every notebook")
+	listCmd.Flags().BoolP("tree", "t", false, "Show the memos as a tree of folders")
 }
 
-func List() {
+const nothingMessage = "You currently have no memo.\nRun `memo new` to get started or `memo help` to get help"
+
+func List(folder string, tree bool) {
 
 	memoDir, err := strconv.Unquote(strconv.Quote(getKeyValue("MemoDir").(string)))
 	if err != nil {
 		log.Fatalf("Error converting MemoDir to string: %v", err)
 	}
 
-	dirExists := DirectoryExists(memoDir)
+	memoList := memoListing(memoDir, folder, tree)
+	if memoList == "" {
+		memoList = nothingMessage
+	}
+
+	printList(memoList)
+}
 
-	nothingMessage := "You currently have no memo.\nRun `memo new` to get started or `memo help` to get help"
+// ListAll lists the memos of every notebook, grouped by notebook
+func ListAll(folder string, tree bool) {
 	var memoList string
-	if dirExists {
+	for _, nb := range allNotebooks() {
+		listing := memoListing(nb.MemoDir, folder, tree)
+		if listing == "" {
+			continue
+		}
+		memoList += fmt.Sprintf("\n[%s]%s\n", nb.Name, listing)
+	}
+
+	if memoList == "" {
+		memoList = nothingMessage
+	}
+
+	printList(strings.TrimSuffix(memoList, "\n"))
+}
+
+func memoListing(memoDir string, folder string, tree bool) string {
+	if tree {
+		return memoTree(memoDir, folder)
+	}
 
-		files, err := os.ReadDir(memoDir)
-		if err != nil {
-			log.Fatalf("Error reading %s with error %v", memoDir, err)
+	var memoList string
+	for _, memo := range readMemos(memoDir) {
+		if !inFolder(memo, folder) {
+			continue
+		}
+		firstNonSpaceLine := getFileTitle(memo.Path)
+		memoInfo := fmt.Sprintf("Memo %d: %s", memo.Number, strings.TrimSpace(firstNonSpaceLine))
+		if memo.Folder != "" {
+			memoInfo += fmt.Sprintf(" (%s)", memo.Folder)
 		}
+		memoList += "\n" + memoInfo
+	}
+
+	return memoList
+}
 
-		// Sort the files before further processing
-		sort.Slice(files, func(i, j int) bool {
-			numI, _ := strconv.Atoi(strings.SplitN(files[i].Name(), "-", 2)[0])
-			numJ, _ := strconv.Atoi(strings.SplitN(files[j].Name(), "-", 2)[0])
-			return numI < numJ
-		})
-		for _, file := range files {
-			if !file.IsDir() {
-				// Get the memo number
-				numberStr := strings.SplitN(file.Name(), "-", 2)[0]
-				number, err := strconv.Atoi(numberStr)
-				if err != nil {
-					log.Printf("Error getting memo number from file %s with error code %v", file.Name(), err)
-					continue
+// memoTree draws the folders under folder with their memos, folders
+// without any memo in them are left out.
+func memoTree(memoDir string, folder string) string {
+	memos := readMemos(memoDir)
+
+	var draw func(folder string, indent string) string
+	draw = func(folder string, indent string) string {
+		type node struct {
+			label  string
+			folder string
+		}
+		var nodes []node
+		for _, memo := range memos {
+			if memo.Folder == folder {
+				title := strings.TrimSpace(getFileTitle(memo.Path))
+				nodes = append(nodes, node{label: fmt.Sprintf("%d: %s", memo.Number, title)})
+			}
+		}
+		for _, sub := range subFolders(memoDir, folder) {
+			subFolder := strings.TrimPrefix(folder+"/"+sub, "/")
+			for _, memo := range memos {
+				if inFolder(memo, subFolder) {
+					nodes = append(nodes, node{label: sub + "/", folder: subFolder})
+					break
 				}
-				firstNonSpaceLine := getFileTitle(filepath.Join(memoDir, file.Name()))
-				memoInfo := fmt.Sprintf("Memo %d: %s", number, strings.TrimSpace(firstNonSpaceLine))
-				memoList += "\n" + memoInfo
 			}
 		}
 
-		if memoList == "" {
-			memoList = nothingMessage
+		var out string
+		for i, n := range nodes {
+			branch, next := "├── ", "│   "
+			if i == len(nodes)-1 {
+				branch, next = "└── ", "    "
+			}
+			out += "\n" + indent + branch + n.label
+			if n.folder != "" {
+				out += draw(n.folder, indent+next)
+			}
 		}
-	} else {
-		memoList = nothingMessage
+
+		return out
+	}
+
+	tree := draw(folder, "")
+	if tree == "" {
+		return ""
 	}
 
+	root := filepath.Base(memoDir)
+	if folder != "" {
+		root = folder + "/"
+	}
+
+	return "\n" + root + tree
+}
+
+func printList(memoList string) {
 	terminalWidth := CalcTermSize()
 	var style = lipgloss.NewStyle().
 		Bold(true).
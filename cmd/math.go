@@ -0,0 +1,668 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// mathNode is a piece of a TeX formula, parsed just enough to be shown
+// as MathML in the browser and as text in the terminal
+type mathNode struct {
+	// mi, mn, mo, mtext, mspace, mrow, mfrac, msqrt, mroot, msup, msub,
+	// msubsup, mover or mtable (rows of mrow cells)
+	kind     string
+	text     string
+	variant  string
+	children []*mathNode
+}
+
+// Commands standing for a letter or a symbol
+var texSymbols = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅", "varnothing": "∅",
+	"hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ",
+}
+
+// Commands standing for an operator or a relation
+var texOperators = map[string]string{
+	"times": "×", "cdot": "⋅", "pm": "±", "mp": "∓", "div": "÷", "ast": "∗", "star": "⋆", "circ": "∘",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈",
+	"equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "leftrightarrow": "↔", "Rightarrow": "⇒",
+	"Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺", "mapsto": "↦",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖", "forall": "∀", "exists": "∃",
+	"neg": "¬", "lnot": "¬", "land": "∧", "wedge": "∧", "lor": "∨", "vee": "∨", "oplus": "⊕",
+	"otimes": "⊗", "perp": "⊥", "parallel": "∥", "mid": "∣", "angle": "∠",
+	"ldots": "…", "cdots": "⋯", "dots": "…", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"lbrace": "{", "rbrace": "}", "vert": "|", "Vert": "‖",
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+	"bigcup": "⋃", "bigcap": "⋂", "bigoplus": "⨁", "bigotimes": "⨂",
+}
+
+// Commands written upright as words
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"log": true, "ln": true, "lg": true, "exp": true, "lim": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "dim": true, "ker": true, "deg": true, "gcd": true,
+	"arg": true, "Pr": true,
+}
+
+// Accents put over what follows them
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→", "dot": "˙",
+	"ddot": "¨", "tilde": "~", "widetilde": "~",
+}
+
+// Spacing commands with their width in em
+var texSpaces = map[string]string{
+	",": "0.167", ":": "0.222", ";": "0.278", " ": "0.25", "quad": "1", "qquad": "2", "!": "0",
+}
+
+var texFonts = map[string]string{
+	"mathbf": "bold", "boldsymbol": "bold", "mathit": "italic", "mathrm": "normal",
+	"mathbb": "double-struck", "mathcal": "script", "mathfrak": "fraktur",
+	"mathsf": "sans-serif", "mathtt": "monospace",
+}
+
+type texParser struct {
+	src []rune
+	pos int
+}
+
+// parseTeX reads a formula, whatever it doesn't understand is kept as
+// text rather than failing
+func parseTeX(src string) *mathNode {
+	p := &texParser{src: []rune(src)}
+	row := p.parseRow(func(*texParser) bool { return false })
+	for p.pos < len(p.src) {
+		// A stray closing brace
+		p.pos++
+		row.children = append(row.children, p.parseRow(func(*texParser) bool { return false }).children...)
+	}
+
+	return row
+}
+
+func (p *texParser) peek() rune {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+
+	return 0
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// atCommand tells whether the next thing is \name
+func (p *texParser) atCommand(name string) bool {
+	end := p.pos + 1 + len([]rune(name))
+	if p.peek() != '\\' || end > len(p.src) || string(p.src[p.pos+1:end]) != name {
+		return false
+	}
+
+	return end == len(p.src) || !unicode.IsLetter(p.src[end])
+}
+
+// parseRow reads atoms until the end, a closing brace or whatever stop
+// recognises
+func (p *texParser) parseRow(stop func(*texParser) bool) *mathNode {
+	row := &mathNode{kind: "mrow"}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.peek() == '}' || stop(p) {
+			return row
+		}
+		if atom := p.parseScripts(); atom != nil {
+			row.children = append(row.children, atom)
+		}
+	}
+}
+
+// parseScripts reads an atom with its sub and superscripts
+func (p *texParser) parseScripts() *mathNode {
+	base := p.parseAtom()
+	var sub, sup *mathNode
+scripts:
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case '_':
+			p.pos++
+			sub = p.parseArgument()
+		case '^':
+			p.pos++
+			sup = p.parseArgument()
+		case '\'':
+			p.pos++
+			sup = &mathNode{kind: "mo", text: "′"}
+		default:
+			break scripts
+		}
+	}
+
+	if base == nil {
+		base = &mathNode{kind: "mrow"}
+	}
+	switch {
+	case sub != nil && sup != nil:
+		return &mathNode{kind: "msubsup", children: []*mathNode{base, sub, sup}}
+	case sub != nil:
+		return &mathNode{kind: "msub", children: []*mathNode{base, sub}}
+	case sup != nil:
+		return &mathNode{kind: "msup", children: []*mathNode{base, sup}}
+	}
+
+	return base
+}
+
+// parseArgument reads a {group} or a single atom
+func (p *texParser) parseArgument() *mathNode {
+	p.skipSpace()
+	if p.peek() == '{' {
+		p.pos++
+		row := p.parseRow(func(*texParser) bool { return false })
+		if p.peek() == '}' {
+			p.pos++
+		}
+		if len(row.children) == 1 {
+			return row.children[0]
+		}
+		return row
+	}
+	if p.pos >= len(p.src) || p.peek() == '}' {
+		return &mathNode{kind: "mrow"}
+	}
+	if unicode.IsDigit(p.peek()) {
+		// One digit like TeX, \frac12 is a half
+		p.pos++
+		return &mathNode{kind: "mn", text: string(p.src[p.pos-1])}
+	}
+
+	return p.parseAtom()
+}
+
+// rawArgument reads a {group} as it is written
+func (p *texParser) rawArgument() string {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return ""
+	}
+
+	depth := 0
+	start := p.pos + 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return string(p.src[start : p.pos-1])
+			}
+		}
+	}
+
+	return string(p.src[start:])
+}
+
+func (p *texParser) parseAtom() *mathNode {
+	if p.pos >= len(p.src) {
+		return nil
+	}
+
+	c := p.peek()
+	switch {
+	case c == '{':
+		return p.parseArgument()
+	case c == '\\':
+		return p.parseCommand()
+	case unicode.IsDigit(c) || c == '.' && p.pos+1 < len(p.src) && unicode.IsDigit(p.src[p.pos+1]):
+		start := p.pos
+		for p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return &mathNode{kind: "mn", text: string(p.src[start:p.pos])}
+	case unicode.IsLetter(c):
+		p.pos++
+		return &mathNode{kind: "mi", text: string(c)}
+	case c == '&':
+		p.pos++
+		return &mathNode{kind: "mspace", text: "1"}
+	case c == '~':
+		p.pos++
+		return &mathNode{kind: "mspace", text: "0.25"}
+	case c == '-':
+		p.pos++
+		return &mathNode{kind: "mo", text: "−"}
+	}
+
+	p.pos++
+	return &mathNode{kind: "mo", text: string(c)}
+}
+
+func (p *texParser) parseCommand() *mathNode {
+	p.pos++ // the backslash
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsLetter(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.src) {
+		// \{, \, and friends
+		p.pos++
+	}
+	name := string(p.src[start:p.pos])
+
+	if width, ok := texSpaces[name]; ok {
+		return &mathNode{kind: "mspace", text: width}
+	}
+	if symbol, ok := texSymbols[name]; ok {
+		return &mathNode{kind: "mi", text: symbol}
+	}
+	if op, ok := texOperators[name]; ok {
+		return &mathNode{kind: "mo", text: op}
+	}
+	if texFunctions[name] {
+		return &mathNode{kind: "mi", text: name}
+	}
+	if accent, ok := texAccents[name]; ok {
+		return &mathNode{kind: "mover", text: accent, children: []*mathNode{p.parseArgument()}}
+	}
+	if variant, ok := texFonts[name]; ok {
+		arg := p.parseArgument()
+		setVariant(arg, variant)
+		return arg
+	}
+
+	switch name {
+	case "{", "}", "|", "#", "%", "$", "_":
+		return &mathNode{kind: "mo", text: name}
+	case "\\":
+		return &mathNode{kind: "mspace", text: "1"}
+	case "frac", "dfrac", "tfrac", "binom":
+		num, den := p.parseArgument(), p.parseArgument()
+		frac := &mathNode{kind: "mfrac", children: []*mathNode{num, den}}
+		if name == "binom" {
+			frac.variant = "binom"
+		}
+		return frac
+	case "sqrt":
+		p.skipSpace()
+		if p.peek() == '[' {
+			p.pos++
+			index := p.parseRow(func(p *texParser) bool { return p.peek() == ']' })
+			if p.peek() == ']' {
+				p.pos++
+			}
+			return &mathNode{kind: "mroot", children: []*mathNode{p.parseArgument(), index}}
+		}
+		return &mathNode{kind: "msqrt", children: []*mathNode{p.parseArgument()}}
+	case "text", "textrm", "textbf", "textit", "mbox", "operatorname":
+		kind := "mtext"
+		if name == "operatorname" {
+			kind = "mi"
+		}
+		return &mathNode{kind: kind, text: p.rawArgument()}
+	case "left", "right", "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr":
+		p.skipSpace()
+		if p.peek() == '.' {
+			p.pos++
+			return nil
+		}
+		return p.parseAtom()
+	case "begin":
+		return p.parseEnvironment(p.rawArgument())
+	case "limits", "nolimits", "displaystyle", "textstyle":
+		return nil
+	}
+
+	return &mathNode{kind: "mtext", text: "\\" + name}
+}
+
+// parseEnvironment reads matrices, cases and aligned equations into a
+// table
+func (p *texParser) parseEnvironment(env string) *mathNode {
+	endOfCell := func(p *texParser) bool {
+		return p.peek() == '&' || (p.peek() == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\\') || p.atCommand("end")
+	}
+
+	table := &mathNode{kind: "mtable"}
+	row := &mathNode{kind: "mrow"}
+	for p.pos < len(p.src) {
+		row.children = append(row.children, p.parseRow(endOfCell))
+		switch {
+		case p.peek() == '&':
+			p.pos++
+			continue
+		case p.atCommand("end"):
+			p.pos += len("\\end")
+			p.rawArgument()
+			table.children = append(table.children, row)
+			return wrapEnvironment(env, table)
+		case p.peek() == '\\':
+			p.pos += 2
+		default:
+			// A stray closing brace
+			p.pos++
+		}
+		table.children = append(table.children, row)
+		row = &mathNode{kind: "mrow"}
+	}
+	table.children = append(table.children, row)
+
+	return wrapEnvironment(env, table)
+}
+
+// wrapEnvironment puts the delimiters of the environment around table
+func wrapEnvironment(env string, table *mathNode) *mathNode {
+	delimiters := map[string][2]string{
+		"pmatrix": {"(", ")"}, "bmatrix": {"[", "]"}, "Bmatrix": {"{", "}"},
+		"vmatrix": {"|", "|"}, "Vmatrix": {"‖", "‖"}, "cases": {"{", ""},
+	}
+	d, ok := delimiters[env]
+	if !ok {
+		return table
+	}
+
+	row := &mathNode{kind: "mrow", children: []*mathNode{{kind: "mo", text: d[0]}, table}}
+	if d[1] != "" {
+		row.children = append(row.children, &mathNode{kind: "mo", text: d[1]})
+	}
+
+	return row
+}
+
+func setVariant(n *mathNode, variant string) {
+	if n == nil {
+		return
+	}
+	switch n.kind {
+	case "mi", "mn", "mo", "mtext":
+		n.variant = variant
+	}
+	for _, child := range n.children {
+		setVariant(child, variant)
+	}
+}
+
+// mathML renders a formula for the browser, the TeX goes along as an
+// annotation for copying
+func mathML(tex string, display bool) string {
+	var out strings.Builder
+	if display {
+		out.WriteString(`<math display="block">`)
+	} else {
+		out.WriteString(`<math>`)
+	}
+	out.WriteString("<semantics>")
+	writeMathML(&out, parseTeX(tex))
+	fmt.Fprintf(&out, `<annotation encoding="application/x-tex">%s</annotation>`, template.HTMLEscapeString(strings.TrimSpace(tex)))
+	out.WriteString("</semantics></math>")
+
+	return out.String()
+}
+
+func writeMathML(out *strings.Builder, n *mathNode) {
+	if n == nil {
+		return
+	}
+
+	switch n.kind {
+	case "mi", "mn", "mo", "mtext":
+		variant := ""
+		if n.variant != "" {
+			variant = fmt.Sprintf(` mathvariant="%s"`, n.variant)
+		}
+		fmt.Fprintf(out, "<%s%s>%s</%s>", n.kind, variant, template.HTMLEscapeString(n.text), n.kind)
+	case "mspace":
+		fmt.Fprintf(out, `<mspace width="%sem"></mspace>`, n.text)
+	case "mover":
+		out.WriteString(`<mover accent="true">`)
+		writeMathML(out, n.children[0])
+		fmt.Fprintf(out, "<mo>%s</mo></mover>", template.HTMLEscapeString(n.text))
+	case "mfrac":
+		if n.variant == "binom" {
+			out.WriteString(`<mrow><mo>(</mo><mfrac linethickness="0">`)
+		} else {
+			out.WriteString("<mfrac>")
+		}
+		for _, child := range n.children {
+			writeMathML(out, asRow(child))
+		}
+		out.WriteString("</mfrac>")
+		if n.variant == "binom" {
+			out.WriteString("<mo>)</mo></mrow>")
+		}
+	case "mtable":
+		out.WriteString("<mtable>")
+		for _, row := range n.children {
+			out.WriteString("<mtr>")
+			for _, cell := range row.children {
+				out.WriteString("<mtd>")
+				writeMathML(out, cell)
+				out.WriteString("</mtd>")
+			}
+			out.WriteString("</mtr>")
+		}
+		out.WriteString("</mtable>")
+	case "mrow":
+		out.WriteString("<mrow>")
+		for _, child := range n.children {
+			writeMathML(out, child)
+		}
+		out.WriteString("</mrow>")
+	default:
+		// msqrt, mroot and the scripts take their children in order,
+		// each of them has to be a single element
+		fmt.Fprintf(out, "<%s>", n.kind)
+		for _, child := range n.children {
+			writeMathML(out, asRow(child))
+		}
+		fmt.Fprintf(out, "</%s>", n.kind)
+	}
+}
+
+// asRow makes sure an empty argument still takes its place
+func asRow(n *mathNode) *mathNode {
+	if n == nil {
+		return &mathNode{kind: "mrow"}
+	}
+
+	return n
+}
+
+var (
+	superscripts = scriptRunes("0123456789+−=()ni′", "⁰¹²³⁴⁵⁶⁷⁸⁹⁺⁻⁼⁽⁾ⁿⁱ′")
+	subscripts   = scriptRunes("0123456789+−=()aeijknx", "₀₁₂₃₄₅₆₇₈₉₊₋₌₍₎ₐₑᵢⱼₖₙₓ")
+)
+
+func scriptRunes(from string, to string) map[rune]rune {
+	small := map[rune]rune{}
+	toRunes := []rune(to)
+	for i, r := range []rune(from) {
+		small[r] = toRunes[i]
+	}
+
+	return small
+}
+
+// Operators read better with some room around them
+const spacedOperators = "=≠≈≡∼≃≅∝<>≤≥≪≫→←↔⇒⇐⇔⟹⟺↦∈∉∋⊂⊆⊃⊇+±∓×÷⋅"
+
+// mathText writes a formula with unicode characters for the terminal
+func mathText(tex string) string {
+	return strings.Join(strings.Fields(writeMathText(parseTeX(tex))), " ")
+}
+
+func writeMathText(n *mathNode) string {
+	if n == nil {
+		return ""
+	}
+
+	switch n.kind {
+	case "mi", "mn", "mtext":
+		return n.text
+	case "mo":
+		if n.text == "−" || strings.Contains(spacedOperators, n.text) {
+			return " " + n.text + " "
+		}
+		return n.text
+	case "mspace":
+		if n.text == "0" {
+			return ""
+		}
+		return " "
+	case "mrow":
+		var text string
+		for _, child := range n.children {
+			text += writeMathText(child)
+			if isFunction(child) {
+				text += " "
+			}
+		}
+		return text
+	case "mfrac":
+		num, den := writeMathText(n.children[0]), writeMathText(n.children[1])
+		if n.variant == "binom" {
+			return fmt.Sprintf("C(%s, %s)", strings.TrimSpace(num), strings.TrimSpace(den))
+		}
+		return groupText(num) + "/" + groupText(den)
+	case "msqrt":
+		return "√" + groupText(writeMathText(n.children[0]))
+	case "mroot":
+		return scriptText(writeMathText(n.children[1]), superscripts, "^") + "√" + groupText(writeMathText(n.children[0]))
+	case "msup":
+		return writeMathText(n.children[0]) + scriptText(writeMathText(n.children[1]), superscripts, "^")
+	case "msub":
+		return writeMathText(n.children[0]) + scriptText(writeMathText(n.children[1]), subscripts, "_")
+	case "msubsup":
+		return writeMathText(n.children[0]) + scriptText(writeMathText(n.children[1]), subscripts, "_") + scriptText(writeMathText(n.children[2]), superscripts, "^")
+	case "mover":
+		marks := map[string]string{"^": "̂", "¯": "̅", "→": "⃗", "˙": "̇", "¨": "̈", "~": "̃"}
+		base := writeMathText(n.children[0])
+		if len([]rune(strings.TrimSpace(base))) == 1 {
+			return strings.TrimSpace(base) + marks[n.text]
+		}
+		return groupText(base) + n.text
+	case "mtable":
+		var rows []string
+		for _, row := range n.children {
+			var cells []string
+			for _, cell := range row.children {
+				cells = append(cells, strings.TrimSpace(writeMathText(cell)))
+			}
+			rows = append(rows, strings.Join(cells, ", "))
+		}
+		return "[" + strings.Join(rows, "; ") + "]"
+	}
+
+	return ""
+}
+
+// isFunction tells \sin and \sin^2 from a letter, they need a space
+// before their argument
+func isFunction(n *mathNode) bool {
+	for n != nil && (n.kind == "msub" || n.kind == "msup" || n.kind == "msubsup") {
+		n = n.children[0]
+	}
+
+	return n != nil && n.kind == "mi" && len([]rune(n.text)) > 1
+}
+
+// groupText puts parentheses around text longer than a symbol
+func groupText(text string) string {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) <= 1 || strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		return text
+	}
+
+	return "(" + text + ")"
+}
+
+// scriptText writes a script with the small characters when they all
+// exist, else as ^(...) or _(...)
+func scriptText(text string, small map[rune]rune, mark string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), " ", "")
+
+	var converted strings.Builder
+	for _, r := range text {
+		s, ok := small[r]
+		if !ok {
+			return mark + groupText(text)
+		}
+		converted.WriteRune(s)
+	}
+
+	return converted.String()
+}
+
+// isFormula tells $5 and $10 apart from $x^2$, a formula starts and
+// ends next to its dollars and no digit follows the closing one
+func isFormula(tex string, after string) bool {
+	if tex == "" || unicode.IsSpace(rune(tex[0])) || unicode.IsSpace(rune(tex[len(tex)-1])) {
+		return false
+	}
+
+	return after == "" || !unicode.IsDigit(rune(after[0]))
+}
+
+// renderMath is a render hook writing $...$ and $$...$$ as MathML
+func renderMath(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	switch n := node.(type) {
+	case *ast.Math:
+		tex := string(n.Literal)
+		if !isFormula(tex, textAfter(n)) {
+			io.WriteString(w, template.HTMLEscapeString("$"+tex+"$"))
+			return ast.GoToNext, true
+		}
+		io.WriteString(w, mathML(tex, false))
+	case *ast.MathBlock:
+		if entering {
+			io.WriteString(w, mathML(string(n.Literal), true)+"\n")
+		}
+		return ast.SkipChildren, true
+	default:
+		return ast.GoToNext, false
+	}
+
+	return ast.GoToNext, true
+}
+
+// textAfter is the text right after node in its paragraph
+func textAfter(node ast.Node) string {
+	parent := node.GetParent()
+	if parent == nil {
+		return ""
+	}
+	children := parent.GetChildren()
+	for i, child := range children {
+		if child == node && i+1 < len(children) {
+			if text, ok := children[i+1].(*ast.Text); ok {
+				return string(text.Literal)
+			}
+		}
+	}
+
+	return ""
+}
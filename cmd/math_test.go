@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// mathMLBody is the MathML of tex without the math, semantics and
+// annotation around it
+func mathMLBody(tex string) string {
+	body := strings.TrimPrefix(mathML(tex, false), "<math><semantics>")
+	body, _, _ = strings.Cut(body, "<annotation")
+
+	return body
+}
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want string
+	}{
+		{`x`, `<mrow><mi>x</mi></mrow>`},
+		{`3.14`, `<mrow><mn>3.14</mn></mrow>`},
+		{`-x`, `<mrow><mo>−</mo><mi>x</mi></mrow>`},
+		{`a < b`, `<mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>`},
+		{`x^2`, `<mrow><msup><mi>x</mi><mn>2</mn></msup></mrow>`},
+		{`x^23`, `<mrow><msup><mi>x</mi><mn>2</mn></msup><mn>3</mn></mrow>`},
+		{`x_i^2`, `<mrow><msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup></mrow>`},
+		{`a_{ij}`, `<mrow><msub><mi>a</mi><mrow><mi>i</mi><mi>j</mi></mrow></msub></mrow>`},
+		{`f'(x)`, `<mrow><msup><mi>f</mi><mo>′</mo></msup><mo>(</mo><mi>x</mi><mo>)</mo></mrow>`},
+		{`\frac{a}{b}`, `<mrow><mfrac><mi>a</mi><mi>b</mi></mfrac></mrow>`},
+		{`\frac12`, `<mrow><mfrac><mn>1</mn><mn>2</mn></mfrac></mrow>`},
+		{`\frac{1}{x+1}`, `<mrow><mfrac><mn>1</mn><mrow><mi>x</mi><mo>+</mo><mn>1</mn></mrow></mfrac></mrow>`},
+		{`\binom{n}{k}`, `<mrow><mrow><mo>(</mo><mfrac linethickness="0"><mi>n</mi><mi>k</mi></mfrac><mo>)</mo></mrow></mrow>`},
+		{`\sqrt{x}`, `<mrow><msqrt><mi>x</mi></msqrt></mrow>`},
+		{`\sqrt[3]{x}`, `<mrow><mroot><mi>x</mi><mrow><mn>3</mn></mrow></mroot></mrow>`},
+		{`\alpha + \Omega`, `<mrow><mi>α</mi><mo>+</mo><mi>Ω</mi></mrow>`},
+		{`a \leq b`, `<mrow><mi>a</mi><mo>≤</mo><mi>b</mi></mrow>`},
+		{`\sin x`, `<mrow><mi>sin</mi><mi>x</mi></mrow>`},
+		{`\operatorname{rank} A`, `<mrow><mi>rank</mi><mi>A</mi></mrow>`},
+		{`\text{if } x`, `<mrow><mtext>if </mtext><mi>x</mi></mrow>`},
+		{`\hat{x}`, `<mrow><mover accent="true"><mi>x</mi><mo>^</mo></mover></mrow>`},
+		{`\mathbb{R}`, `<mrow><mi mathvariant="double-struck">R</mi></mrow>`},
+		{`\mathbf{v}_1`, `<mrow><msub><mi mathvariant="bold">v</mi><mn>1</mn></msub></mrow>`},
+		{`a \, b`, `<mrow><mi>a</mi><mspace width="0.167em"></mspace><mi>b</mi></mrow>`},
+		{`\{x\}`, `<mrow><mo>{</mo><mi>x</mi><mo>}</mo></mrow>`},
+		{`\left( x \right)`, `<mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow>`},
+		{`\sum_{i=1}^n i`, `<mrow><msubsup><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup><mi>i</mi></mrow>`},
+		{`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`, `<mrow><mrow><mo>(</mo><mtable><mtr><mtd><mrow><mi>a</mi></mrow></mtd><mtd><mrow><mi>b</mi></mrow></mtd></mtr><mtr><mtd><mrow><mi>c</mi></mrow></mtd><mtd><mrow><mi>d</mi></mrow></mtd></mtr></mtable><mo>)</mo></mrow></mrow>`},
+		{`\begin{cases} 1 & x > 0 \\ 0 & \text{else} \end{cases}`, `<mrow><mrow><mo>{</mo><mtable><mtr><mtd><mrow><mn>1</mn></mrow></mtd><mtd><mrow><mi>x</mi><mo>&gt;</mo><mn>0</mn></mrow></mtd></mtr><mtr><mtd><mrow><mn>0</mn></mrow></mtd><mtd><mrow><mtext>else</mtext></mrow></mtd></mtr></mtable></mrow></mrow>`},
+		// Unknown commands are kept as they are written
+		{`\foo x`, `<mrow><mtext>\foo</mtext><mi>x</mi></mrow>`},
+	}
+
+	for _, test := range tests {
+		if got := mathMLBody(test.tex); got != test.want {
+			t.Errorf("mathML(%q)\n got %s\nwant %s", test.tex, got, test.want)
+		}
+	}
+}
+
+func TestMathMLWrapper(t *testing.T) {
+	got := mathML(`a<b`, true)
+	want := `<math display="block"><semantics><mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow><annotation encoding="application/x-tex">a&lt;b</annotation></semantics></math>`
+	if got != want {
+		t.Errorf("mathML(%q, true)\n got %s\nwant %s", `a<b`, got, want)
+	}
+}
+
+func TestMathText(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want string
+	}{
+		{`x^2`, `x²`},
+		{`x_i^2`, `xᵢ²`},
+		{`x^{n+1}`, `xⁿ⁺¹`},
+		{`x_{ab}`, `x_(ab)`},
+		{`e^{i\pi} = -1`, `e^(iπ) = − 1`},
+		{`\frac{a}{b}`, `a/b`},
+		{`\frac{1}{x+1}`, `1/(x + 1)`},
+		{`\binom{n}{k}`, `C(n, k)`},
+		{`\sqrt{x}`, `√x`},
+		{`\sqrt[3]{x}`, `³√x`},
+		{`\sin x`, `sin x`},
+		{`\sin^2 x`, `sin² x`},
+		{`\hat{x}`, "x̂"},
+		{`\vec{AB}`, `(AB)→`},
+		{`\sum_{i=1}^n i`, `∑ᵢ₌₁ⁿi`},
+		{`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`, `([a, b; c, d])`},
+	}
+
+	for _, test := range tests {
+		if got := mathText(test.tex); got != test.want {
+			t.Errorf("mathText(%q) = %q, want %q", test.tex, got, test.want)
+		}
+	}
+}
+
+// Whatever is typed between dollars has to render, unfinished formulas
+// are written while the preview is open
+func TestMathMalformed(t *testing.T) {
+	for _, tex := range []string{
+		`}`, `{`, `}}x{{`, `x^`, `a_`, `^^`, `_`, `'`, `\`, `\\`, `\frac`, `\frac{`, `\sqrt[`, `\sqrt[3`,
+		`\text{`, `\left`, `\right.`, `\begin{matrix}`, `\begin{matrix} a &`, `\end{matrix}`,
+		`\begin{cases} a \\`, `\mathbb`, `\hat`, `x^{y_{z`,
+	} {
+		done := make(chan string, 1)
+		go func() {
+			done <- mathML(tex, false) + mathText(tex)
+		}()
+
+		select {
+		case out := <-done:
+			if strings.Count(out, "<mrow>") != strings.Count(out, "</mrow>") {
+				t.Errorf("mathML(%q) isn't balanced: %s", tex, out)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("mathML(%q) doesn't finish", tex)
+		}
+	}
+}
+
+func TestIsFormula(t *testing.T) {
+	tests := []struct {
+		tex   string
+		after string
+		want  bool
+	}{
+		{`x^2`, "", true},
+		{`x`, " and", true},
+		{`5 and `, "10", false},
+		{`5 and`, "10", false},
+		{` x`, "", false},
+		{``, "", false},
+	}
+
+	for _, test := range tests {
+		if got := isFormula(test.tex, test.after); got != test.want {
+			t.Errorf("isFormula(%q, %q) = %v, want %v", test.tex, test.after, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Memos can start with a front matter block of "key: value" lines:
+//
+//	---
+//	title: Something memorable
+//	tags: [work, ideas]
+//	---
+//
+// Lists are written either inline like above or as "- item" lines.
+type frontMatter map[string][]string
+
+// get returns the first value of key
+func (fm frontMatter) get(key string) string {
+	if values := fm[key]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// splitFrontMatter separates the front matter from the rest of a memo,
+// content without one is returned as is.
+func splitFrontMatter(content string) (frontMatter, string) {
+	fm := frontMatter{}
+
+	normalised := strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(normalised, "---\n") {
+		return fm, content
+	}
+
+	lines := strings.Split(normalised, "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if line := strings.TrimSpace(lines[i]); line == "---" || line == "..." {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return fm, content
+	}
+
+	var key string
+	for _, line := range lines[1:end] {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Items of a list started on an earlier line
+		if strings.HasPrefix(trimmed, "- ") && key != "" {
+			fm[key] = append(fm[key], unquote(strings.TrimPrefix(trimmed, "- ")))
+			continue
+		}
+
+		k, value, found := strings.Cut(trimmed, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(k))
+		value = strings.TrimSpace(value)
+
+		switch {
+		case value == "":
+			fm[key] = nil
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			var items []string
+			for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					items = append(items, item)
+				}
+			}
+			fm[key] = items
+		default:
+			fm[key] = []string{unquote(value)}
+		}
+	}
+
+	return fm, strings.Join(lines[end+1:], "\n")
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// Inline tags look like #tag, a heading needs a space after the #
+var inlineTagRegex = regexp.MustCompile(`(?:^|[\s(])#(\p{L}[\p{L}\p{N}_/-]*)`)
+
+// memoTags collects the tags in the front matter and the #tags in the
+// text of a memo, code blocks are left out.
+func memoTags(content string) []string {
+	fm, body := splitFrontMatter(content)
+
+	seen := map[string]bool{}
+	var tags []string
+	add := func(tag string) {
+		tag = strings.ToLower(strings.Trim(strings.TrimSpace(tag), "#"))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	for _, tag := range fm["tags"] {
+		// tags: a, b without brackets
+		for _, t := range strings.Split(tag, ",") {
+			add(t)
+		}
+	}
+
+	inCode := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		for _, m := range inlineTagRegex.FindAllStringSubmatch(line, -1) {
+			add(strings.TrimRight(m[1], "/-"))
+		}
+	}
+
+	sort.Strings(tags)
+	return tags
+}
+
+// Formats accepted for the date in the front matter
+var frontMatterDates = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04"}
+
+// memoDate is when a memo was written: the date in its front matter,
+// else the one in its file name, else when the file last changed.
+func memoDate(memo memoFile, fm frontMatter) time.Time {
+	if value := fm.get("date"); value != "" {
+		for _, layout := range frontMatterDates {
+			if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				return date
+			}
+		}
+	}
+	if !memo.Date.IsZero() {
+		return memo.Date
+	}
+	if info, err := os.Stat(memo.Path); err == nil {
+		return info.ModTime()
+	}
+
+	return time.Time{}
+}
+
+// memoExcerpt is the start of the text of a memo, headings and markup
+// left out, cut to about length runes.
+func memoExcerpt(md string, length int) string {
+	var words []string
+	size := 0
+	inCode := false
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "|") {
+			continue
+		}
+
+		trimmed = strings.TrimLeft(trimmed, "-*+> ")
+		trimmed = excerptMarkup.ReplaceAllString(trimmed, "$1$2")
+		for _, word := range strings.Fields(trimmed) {
+			words = append(words, word)
+			size += len([]rune(word)) + 1
+			if size >= length {
+				return strings.Join(words, " ") + "…"
+			}
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Links and images keep their text, escaped characters lose their
+// backslash, emphasis and code marks go. Underscores are left alone,
+// they are more often part of a name.
+var excerptMarkup = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)|\\([[:punct:]]|$)|[*` + "`" + `]+`)
+
+// setFrontMatter writes key in the front matter of content, creating
+// the block when there is none. No values removes the key.
+func setFrontMatter(content string, key string, values []string) string {
+	line := ""
+	switch len(values) {
+	case 0:
+	case 1:
+		line = key + ": " + values[0]
+	default:
+		line = key + ": [" + strings.Join(values, ", ") + "]"
+	}
+
+	normalised := strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(normalised, "\n")
+	end := -1
+	if strings.HasPrefix(normalised, "---\n") {
+		for i := 1; i < len(lines); i++ {
+			if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		if line == "" {
+			return content
+		}
+		return "---\n" + line + "\n---\n" + content
+	}
+
+	// The old value goes, with the "- item" lines of a list
+	var kept []string
+	at := -1
+	for i := 1; i < end; i++ {
+		k, _, found := strings.Cut(lines[i], ":")
+		if found && !strings.HasPrefix(lines[i], " ") && strings.EqualFold(strings.TrimSpace(k), key) {
+			at = len(kept)
+			for i+1 < end && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "- ") {
+				i++
+			}
+			continue
+		}
+		kept = append(kept, lines[i])
+	}
+	if at < 0 {
+		at = len(kept)
+	}
+	if line != "" {
+		kept = append(kept[:at], append([]string{line}, kept[at:]...)...)
+	}
+	if strings.TrimSpace(strings.Join(kept, "")) == "" {
+		return strings.Join(lines[end+1:], "\n")
+	}
+
+	return strings.Join(append(append([]string{"---"}, kept...), lines[end:]...), "\n")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		fm      frontMatter
+		body    string
+	}{
+		{"none", "# Title\n", frontMatter{}, "# Title\n"},
+		{"values", "---\ntitle: Hello\ndate: 2024-01-17\n---\n# Body\n",
+			frontMatter{"title": {"Hello"}, "date": {"2024-01-17"}}, "# Body\n"},
+		{"inline list", "---\ntags: [work, \"big ideas\", ]\n---\nx",
+			frontMatter{"tags": {"work", "big ideas"}}, "x"},
+		{"item list", "---\ntags:\n  - work\n  - 'ideas'\ntitle: T\n---\nx",
+			frontMatter{"tags": {"work", "ideas"}, "title": {"T"}}, "x"},
+		{"keys lower cased", "---\nTitle: Hello\n---\n", frontMatter{"title": {"Hello"}}, ""},
+		{"comments and colons", "---\n# a comment\nremind: 2024-01-17 09:30\n---\n",
+			frontMatter{"remind": {"2024-01-17 09:30"}}, ""},
+		{"dots end it", "---\ntitle: Hello\n...\nx", frontMatter{"title": {"Hello"}}, "x"},
+		{"windows lines", "---\r\ntitle: Hello\r\n---\r\nx\r\n", frontMatter{"title": {"Hello"}}, "x\n"},
+		{"not closed", "---\ntitle: Hello\n", frontMatter{}, "---\ntitle: Hello\n"},
+		{"not at the start", "\n---\ntitle: Hello\n---\n", frontMatter{}, "\n---\ntitle: Hello\n---\n"},
+		{"a rule", "---\n", frontMatter{}, "---\n"},
+	}
+
+	for _, test := range tests {
+		fm, body := splitFrontMatter(test.content)
+		if !reflect.DeepEqual(fm, test.fm) || body != test.body {
+			t.Errorf("%s: splitFrontMatter(%q) = %v, %q, want %v, %q", test.name, test.content, fm, body, test.fm, test.body)
+		}
+	}
+}
+
+func TestSetFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		key     string
+		values  []string
+		want    string
+	}{
+		{"new block", "# Title\n", "tags", []string{"a"}, "---\ntags: a\n---\n# Title\n"},
+		{"list", "# Title\n", "tags", []string{"a", "b"}, "---\ntags: [a, b]\n---\n# Title\n"},
+		{"nothing to remove", "# Title\n", "tags", nil, "# Title\n"},
+		{"added at the end", "---\ntitle: T\n---\nx", "tags", []string{"a"}, "---\ntitle: T\ntags: a\n---\nx"},
+		{"replaced in place", "---\ntags: a\ntitle: T\n---\nx", "tags", []string{"b"}, "---\ntags: b\ntitle: T\n---\nx"},
+		{"item list replaced", "---\nTags:\n  - a\n  - b\ntitle: T\n---\nx", "tags", []string{"c"}, "---\ntags: c\ntitle: T\n---\nx"},
+		{"removed", "---\ntags: a\ntitle: T\n---\nx", "tags", nil, "---\ntitle: T\n---\nx"},
+		{"last key removed", "---\ntags: a\n---\nx", "tags", nil, "x"},
+		{"other keys kept", "---\ntagsx: a\n---\nx", "tags", nil, "---\ntagsx: a\n---\nx"},
+	}
+
+	for _, test := range tests {
+		got := setFrontMatter(test.content, test.key, test.values)
+		if got != test.want {
+			t.Errorf("%s: setFrontMatter(%q, %q, %q) = %q, want %q", test.name, test.content, test.key, test.values, got, test.want)
+		}
+
+		// What was written is read back
+		fm, _ := splitFrontMatter(got)
+		if values := fm[test.key]; len(test.values) > 0 && !reflect.DeepEqual(values, test.values) {
+			t.Errorf("%s: %q reads back as %q", test.name, test.key, values)
+		}
+	}
+}
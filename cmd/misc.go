@@ -6,7 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -60,35 +60,160 @@ func CalcTermSize() int {
 	return terminalWidth
 }
 
-func matchMemoNumber(memoNumber int) string {
-	memoDir := getKeyValue("MemoDir").(string)
+// memoFile is a memo found on disk
+type memoFile struct {
+	memoName
+	Path string
+	// Folder is relative to the memo directory, empty for the top
+	Folder string
+}
 
-	isDirectoryThere := DirectoryExists(memoDir)
+// readMemos returns the memos in memoDir and its folders sorted by their
+// number, files that don't look like memos, hidden folders and the
+// attachment folders are skipped.
+//
+// Memos whose file name has no number (the scheme has no {n}) use the
+// number in their front matter, memo writes one when it creates them.
+// The ones without it, or with a number that is taken, get the free
+// numbers in the order of their names.
+func readMemos(memoDir string) []memoFile {
+	schemes := memoSchemes()
 
-	if !isDirectoryThere {
-		log.Fatalf("You can't delete any memo, you have none :)")
+	var memos, unnumbered []memoFile
+	filepath.WalkDir(memoDir, func(path string, file os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if file.IsDir() {
+			if path != memoDir && (strings.HasPrefix(file.Name(), ".") || isAssetsDir(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(memoDir, path)
+		if err != nil {
+			return nil
+		}
+		folder := filepath.ToSlash(filepath.Dir(rel))
+		if folder == "." {
+			folder = ""
+		}
+
+		for _, scheme := range schemes {
+			name, ok := scheme.parse(filepath.ToSlash(rel))
+			if !ok {
+				continue
+			}
+			memo := memoFile{memoName: name, Path: path, Folder: folder}
+			if scheme.hasNumber() {
+				memos = append(memos, memo)
+			} else {
+				unnumbered = append(unnumbered, memo)
+			}
+			break
+		}
+		return nil
+	})
+
+	taken := map[int]bool{}
+	for _, memo := range memos {
+		taken[memo.Number] = true
+	}
+
+	// Sorted first so the same memo keeps a number used twice
+	sort.Slice(unnumbered, func(i, j int) bool {
+		if unnumbered[i].Name != unnumbered[j].Name {
+			return unnumbered[i].Name < unnumbered[j].Name
+		}
+		return unnumbered[i].Path < unnumbered[j].Path
+	})
+	var left []memoFile
+	for _, memo := range unnumbered {
+		if number := storedNumber(memo.Path); number > 0 && !taken[number] {
+			memo.Number = number
+			taken[number] = true
+			memos = append(memos, memo)
+			continue
+		}
+		left = append(left, memo)
 	}
 
-	files, err := os.ReadDir(memoDir)
+	next := 1
+	for _, memo := range left {
+		for taken[next] {
+			next++
+		}
+		memo.Number = next
+		taken[next] = true
+		memos = append(memos, memo)
+	}
+
+	sort.Slice(memos, func(i, j int) bool {
+		return memos[i].Number < memos[j].Number
+	})
+
+	return memos
+}
+
+// storedNumber is the number in the front matter of a memo, 0 when it
+// has none
+func storedNumber(filename string) int {
+	content, err := os.ReadFile(filename)
 	if err != nil {
-		log.Fatalf("Couldn't read the contents of %s", memoDir)
+		return 0
 	}
 
-	var matchedFile string
+	fm, _ := splitFrontMatter(string(content))
+	number, err := strconv.Atoi(fm.get("number"))
+	if err != nil || number < 1 {
+		return 0
+	}
 
-	for _, file := range files {
-		matches := regexp.MustCompile(`^(\d+)-\d{4}-\d{2}-\d{2}-(.+)\.md$`).FindStringSubmatch(file.Name())
+	return number
+}
 
-		if len(matches) >= 3 {
-			currentMemoNumber, err := strconv.Atoi(matches[1])
-			if err != nil {
-				continue
-			}
+// memoByNumber finds the memo with the given number in memoDir
+func memoByNumber(memoDir string, number int) (memoFile, bool) {
+	for _, memo := range readMemos(memoDir) {
+		if memo.Number == number {
+			return memo, true
+		}
+	}
+
+	return memoFile{}, false
+}
+
+// memoByPath finds the memo stored in filename
+func memoByPath(filename string) (memoFile, bool) {
+	for _, memo := range readMemos(getKeyValue("MemoDir").(string)) {
+		if memo.Path == filename {
+			return memo, true
+		}
+	}
+
+	return memoFile{}, false
+}
 
-			// Check if the memo number matches the provided memoNumber
-			if currentMemoNumber == memoNumber {
-				// Append to MemoDir
-				matchedFile = filepath.Join(memoDir, file.Name())
+// matchMemo finds a memo by its number, id, slug or file name
+func matchMemo(ref string) string {
+	if number, err := strconv.Atoi(ref); err == nil && len(ref) < 14 {
+		return matchMemoNumber(number)
+	}
+
+	memoDir := getKeyValue("MemoDir").(string)
+	ref = strings.TrimSuffix(filepath.ToSlash(ref), "/")
+
+	var matchedFile string
+	for _, memo := range readMemos(memoDir) {
+		rel, _ := filepath.Rel(memoDir, memo.Path)
+		switch ref {
+		case memo.ID, memo.Name, filepath.ToSlash(rel), filepath.Base(memo.Path):
+			return memo.Path
+		case memo.Slug:
+			// Slugs aren't unique, the first one wins
+			if matchedFile == "" {
+				matchedFile = memo.Path
 			}
 		}
 	}
@@ -96,6 +221,63 @@ func matchMemoNumber(memoNumber int) string {
 	return matchedFile
 }
 
+// cleanFolder normalises a folder given by the user, it has to stay
+// inside the memo directory.
+func cleanFolder(folder string) (string, error) {
+	folder = filepath.ToSlash(filepath.Clean("/" + strings.TrimSpace(folder)))
+	folder = strings.Trim(folder, "/")
+	for _, part := range strings.Split(folder, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("%q is not a valid folder name", part)
+		}
+	}
+
+	return folder, nil
+}
+
+// inFolder reports if memo lives in folder or any of its sub folders
+func inFolder(memo memoFile, folder string) bool {
+	return folder == "" || memo.Folder == folder || strings.HasPrefix(memo.Folder, folder+"/")
+}
+
+// subFolders lists the folders directly inside folder
+func subFolders(memoDir string, folder string) []string {
+	entries, err := os.ReadDir(filepath.Join(memoDir, folder))
+	if err != nil {
+		return nil
+	}
+
+	var folders []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && !isAssetsDir(filepath.Join(memoDir, folder, entry.Name())) {
+			folders = append(folders, entry.Name())
+		}
+	}
+
+	return folders
+}
+
+func matchMemoNumber(memoNumber int) string {
+	memoDir := getKeyValue("MemoDir").(string)
+
+	isDirectoryThere := DirectoryExists(memoDir)
+
+	if !isDirectoryThere {
+		log.Fatalf("You can't delete any memo, you have none :)")
+	}
+
+	var matchedFile string
+
+	for _, memo := range readMemos(memoDir) {
+		// Check if the memo number matches the provided memoNumber
+		if memo.Number == memoNumber {
+			matchedFile = memo.Path
+		}
+	}
+
+	return matchedFile
+}
+
 func openEditor(fileName string, oTitle ...string) error {
 	editor, err := strconv.Unquote(strconv.Quote(getKeyValue("Editor").(string)))
 	if err != nil {
@@ -110,7 +292,7 @@ func openEditor(fileName string, oTitle ...string) error {
 	}
 	// If title is provided, write it to the file
 	if title != "" {
-		title = "# " + title + "\n\n"
+		title = numberMemo(newMemoContent(title, formatOf(fileName)))
 		err := os.WriteFile(fileName, []byte(title), 0644)
 		if err != nil {
 			log.Fatal(err)
@@ -132,25 +314,43 @@ func openEditor(fileName string, oTitle ...string) error {
 }
 
 func getFileTitle(filename string) string {
-	content, err := os.ReadFile(filename)
+	title, err := fileTitle(filename)
 	if err != nil {
 		log.Fatal("Error reading content from", filename)
 	}
 
-	lines := strings.Split(string(content), "\n")
+	return title
+}
+
+// memoTitle is the title of a memo for memo serve, the file name when
+// the memo can't be read so that one bad file doesn't stop the server
+func memoTitle(filename string) string {
+	title, err := fileTitle(filename)
+	if err != nil {
+		return filepath.Base(filename)
+	}
+
+	return title
+}
 
-	var firstNonSpaceLine string
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		if trimmedLine != "" {
-			firstNonSpaceLine = trimmedLine
-			break
-		}
+func fileTitle(filename string) (string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	fm, body := splitFrontMatter(string(content))
+	lines := strings.Split(body, "\n")
+
+	// The front matter wins, otherwise every format has its own way
+	// of marking the title
+	title := fm.get("title")
+	if title == "" {
+		title = formatOf(filename).title(lines)
 	}
-	if firstNonSpaceLine == "" {
-		firstNonSpaceLine = "No title for this file"
+	if title == "" {
+		title = "No title for this file"
 	}
 
-	firstNonSpaceLine = strings.TrimPrefix(firstNonSpaceLine, "#")
-	return firstNonSpaceLine
+	return title, nil
 }
@@ -0,0 +1,84 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var moveCmd = &cobra.Command{
+	Use:     "move <memo> <folder>",
+	Aliases: []string{"mv"},
+	Short:   "Move a memo into another folder",
+	Long:    `Move a memo into another folder inside the memo directory, use / for the top`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		folder, err := cleanFolder(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		filename := matchMemo(args[0])
+		if filename == "" {
+			log.Fatalf("[%s]: Can't move memo, couldn't match any file", args[0])
+		}
+
+		newName, err := moveMemo(filename, folder)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Moved %s to %s\n", filename, newName)
+
+		commitMsg := fmt.Sprintf("[Move]: %s", getFileTitle(newName))
+		if err := commit(commitMsg, filename, newName, assetsDir(filename), assetsDir(newName)); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(moveCmd)
+}
+
+// moveMemo moves filename and its attachments into folder and returns
+// where it ended up
+func moveMemo(filename string, folder string) (string, error) {
+	memoDir := getKeyValue("MemoDir").(string)
+
+	// Keep the part of the path that comes from the file name scheme
+	name := filepath.Base(filename)
+	if memo, ok := memoByPath(filename); ok {
+		name = memo.Name
+	}
+
+	newName := filepath.Join(memoDir, folder, name)
+	if err := os.MkdirAll(filepath.Dir(newName), 0700); err != nil {
+		return "", err
+	}
+
+	if newName == filename {
+		return newName, nil
+	}
+	if FileExists(newName) || DirectoryExists(assetsDir(newName)) {
+		return "", fmt.Errorf("%s already exists", newName)
+	}
+
+	if err := os.Rename(filename, newName); err != nil {
+		return "", err
+	}
+
+	// The attachments go with the memo
+	if DirectoryExists(assetsDir(filename)) {
+		if err := os.Rename(assetsDir(filename), assetsDir(newName)); err != nil {
+			return "", err
+		}
+	}
+
+	return newName, nil
+}
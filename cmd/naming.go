@@ -0,0 +1,261 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// The file name scheme memo has always used
+const defaultFileNames = "{n}-{date}-{slug}.md"
+
+// The placeholders that can be used in the FileNames setting and
+// what they look like in a file name.
+//
+//	{n}     the memo number (1, 2, 3...)
+//	{id}    a timestamp id (20240117190532)
+//	{date}  the creation date (2024-01-17)
+//	{yyyy}  the creation year
+//	{mm}    the creation month
+//	{dd}    the creation day
+//	{slug}  the title made safe for a file name
+var schemePlaceholders = map[string]string{
+	"n":    `\d+`,
+	"id":   `\d{14}`,
+	"date": `\d{4}-\d{2}-\d{2}`,
+	"yyyy": `\d{4}`,
+	"mm":   `\d{2}`,
+	"dd":   `\d{2}`,
+	"slug": `[^/]+?`,
+}
+
+var placeholderRegex = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// fileScheme turns memo details into file names and back. The
+// extension in the pattern is swapped for the one of the memo format.
+type fileScheme struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// memoName is what can be read back from a file name
+type memoName struct {
+	Number int
+	ID     string
+	Slug   string
+	Date   time.Time
+	// The part of the path matched by the scheme
+	Name string
+}
+
+func compileScheme(pattern string) (*fileScheme, error) {
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var exts []string
+	for _, ext := range memoExtensions() {
+		pattern = strings.TrimSuffix(pattern, ext)
+		exts = append(exts, regexp.QuoteMeta(ext))
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("the file name scheme is empty")
+	}
+
+	seen := map[string]bool{}
+	var expr strings.Builder
+	last := 0
+	for _, loc := range placeholderRegex.FindAllStringSubmatchIndex(pattern, -1) {
+		name := pattern[loc[2]:loc[3]]
+		sub, ok := schemePlaceholders[name]
+		if !ok {
+			return nil, fmt.Errorf("{%s} is not a known placeholder in %q", name, pattern)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("{%s} is used more than once in %q", name, pattern)
+		}
+		seen[name] = true
+
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		expr.WriteString(fmt.Sprintf("(?P<%s>%s)", name, sub))
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString(`(?:` + strings.Join(exts, "|") + `)`)
+
+	// Without one of these memos made on the same day would clash
+	if !seen["n"] && !seen["id"] && !seen["slug"] {
+		return nil, fmt.Errorf("%q needs at least one of {n}, {id} or {slug}", pattern)
+	}
+
+	re, err := regexp.Compile(`(?:^|/)(` + expr.String() + `)$`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileScheme{pattern: pattern, re: re}, nil
+}
+
+func (s *fileScheme) hasNumber() bool {
+	return strings.Contains(s.pattern, "{n}")
+}
+
+// render builds the file name of a memo, relative to its folder
+func (s *fileScheme) render(number int, created time.Time, slug string, format memoFormat) string {
+	return strings.NewReplacer(
+		"{n}", strconv.Itoa(number),
+		"{id}", created.Format("20060102150405"),
+		"{date}", created.Format("2006-01-02"),
+		"{yyyy}", created.Format("2006"),
+		"{mm}", created.Format("01"),
+		"{dd}", created.Format("02"),
+		"{slug}", slug,
+	).Replace(s.pattern) + format.Extensions[0]
+}
+
+// parse reads the memo details from a slash separated path relative
+// to the memo directory.
+func (s *fileScheme) parse(rel string) (memoName, bool) {
+	matches := s.re.FindStringSubmatch(rel)
+	if matches == nil {
+		return memoName{}, false
+	}
+
+	name := memoName{Name: matches[1]}
+	group := func(key string) string {
+		if i := s.re.SubexpIndex(key); i >= 0 {
+			return matches[i]
+		}
+		return ""
+	}
+
+	if n := group("n"); n != "" {
+		number, err := strconv.Atoi(n)
+		if err != nil {
+			return memoName{}, false
+		}
+		name.Number = number
+	}
+	name.ID = group("id")
+	name.Slug = group("slug")
+
+	switch {
+	case group("date") != "":
+		name.Date, _ = time.Parse("2006-01-02", group("date"))
+	case group("yyyy") != "":
+		month, day := group("mm"), group("dd")
+		if month == "" {
+			month = "01"
+		}
+		if day == "" {
+			day = "01"
+		}
+		name.Date, _ = time.Parse("2006-01-02", group("yyyy")+"-"+month+"-"+day)
+	case name.ID != "":
+		name.Date, _ = time.Parse("20060102", name.ID[:8])
+	}
+
+	return name, true
+}
+
+// memoSchemes returns the configured file name scheme followed by the
+// default one so memos created before a change are still found.
+func memoSchemes() []*fileScheme {
+	pattern := getKeyValue("FileNames").(string)
+	if pattern == "" {
+		pattern = defaultFileNames
+	}
+
+	configured, err := compileScheme(pattern)
+	if err != nil {
+		log.Fatalf("Invalid filenames setting: %v", err)
+	}
+
+	fallback, _ := compileScheme(defaultFileNames)
+	if configured.pattern == fallback.pattern {
+		return []*fileScheme{configured}
+	}
+
+	return []*fileScheme{configured, fallback}
+}
+
+// Letters that don't decompose into a base letter and accents, and the
+// Greek and Cyrillic ones spelled with latin letters. The hard and soft
+// signs have no sound of their own and are dropped.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d",
+	'ð': "d", 'þ': "th", 'ı': "i", 'ŋ': "ng",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z",
+	'η': "i", 'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m",
+	'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh",
+	'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'є': "ye", 'ґ': "g", 'ј': "j", 'љ': "lj",
+	'њ': "nj", 'ћ': "c", 'ђ': "dj", 'џ': "dz", 'ѕ': "dz",
+}
+
+// slugify makes a title safe to use in a file name. Latin letters lose
+// their accents and Greek and Cyrillic ones are transliterated. Letters
+// and digits from the other scripts (Chinese, Arabic, Hindi...) have no
+// single latin spelling and are kept as they are, everything else
+// becomes an underscore.
+func slugify(title string) string {
+	var slug strings.Builder
+	pendingSep := false
+
+	for _, r := range norm.NFC.String(title) {
+		var part string
+		switch {
+		case unicode.In(r, unicode.Latin, unicode.Greek, unicode.Cyrillic):
+			// Split the accents off and only keep the base letters
+			for _, d := range norm.NFKD.String(string(unicode.ToLower(r))) {
+				if t, ok := transliterations[d]; ok {
+					part += t
+				} else if unicode.IsLetter(d) || unicode.IsDigit(d) {
+					part += string(d)
+				}
+			}
+			if part == "" && unicode.IsLetter(r) {
+				continue
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			part = string(unicode.ToLower(r))
+		}
+
+		if part == "" {
+			pendingSep = true
+			continue
+		}
+		if pendingSep && slug.Len() > 0 {
+			slug.WriteString("_")
+		}
+		pendingSep = false
+		slug.WriteString(part)
+	}
+
+	if slug.Len() == 0 {
+		return "untitled"
+	}
+
+	// Keep the name well under the usual 255 byte limit
+	runes := []rune(slug.String())
+	if len(runes) > 80 {
+		runes = runes[:80]
+	}
+
+	return string(runes)
+}
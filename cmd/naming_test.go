@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello World", "hello_world"},
+		{"  Spaces   around  ", "spaces_around"},
+		{"Café au lait", "cafe_au_lait"},
+		{"Ærø Straße", "aero_strasse"},
+		{"Łódź", "lodz"},
+		{"Привет, мир", "privet_mir"},
+		{"Объект", "obekt"},
+		{"Щука", "shchuka"},
+		{"Ελληνικά Άλφα", "ellinika_alfa"},
+		{"ΣΟΦΟΣ", "sofos"},
+		// No single latin spelling, kept as it is
+		{"日本語のメモ", "日本語のメモ"},
+		{"C++ & Go 1.22", "c_go_1_22"},
+		{"", "untitled"},
+		{"!!!", "untitled"},
+		{strings.Repeat("a", 100), strings.Repeat("a", 80)},
+	}
+
+	for _, test := range tests {
+		if got := slugify(test.title); got != test.want {
+			t.Errorf("slugify(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestCompileSchemeErrors(t *testing.T) {
+	tests := []struct {
+		pattern string
+		err     string
+	}{
+		{"", "empty"},
+		{".md", "empty"},
+		{"{n}-{nope}.md", "not a known placeholder"},
+		{"{n}-{n}.md", "more than once"},
+		{"{date}.md", "needs at least one of"},
+		{"{yyyy}/{mm}.md", "needs at least one of"},
+	}
+
+	for _, test := range tests {
+		_, err := compileScheme(test.pattern)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("compileScheme(%q) = %v, want an error with %q", test.pattern, err, test.err)
+		}
+	}
+}
+
+func TestSchemeRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 17, 19, 5, 32, 0, time.UTC)
+	markdown, _ := findFormat("markdown")
+	org, _ := findFormat("org")
+
+	tests := []struct {
+		pattern string
+		format  memoFormat
+		file    string
+		want    memoName
+	}{
+		{defaultFileNames, markdown, "12-2024-01-17-my_memo.md",
+			memoName{Number: 12, Slug: "my_memo", Date: time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)}},
+		{"{id}-{slug}.md", org, "20240117190532-my_memo.org",
+			memoName{ID: "20240117190532", Slug: "my_memo", Date: time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)}},
+		{"{yyyy}/{mm}/{n}-{slug}.md", markdown, "2024/01/12-my_memo.md",
+			memoName{Number: 12, Slug: "my_memo", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{"/{slug}.md", markdown, "my_memo.md", memoName{Slug: "my_memo"}},
+	}
+
+	for _, test := range tests {
+		scheme, err := compileScheme(test.pattern)
+		if err != nil {
+			t.Fatalf("compileScheme(%q): %v", test.pattern, err)
+		}
+
+		file := scheme.render(12, created, "my_memo", test.format)
+		if file != test.file {
+			t.Errorf("%q renders %q, want %q", test.pattern, file, test.file)
+		}
+
+		test.want.Name = test.file
+		got, ok := scheme.parse("folder/" + file)
+		if !ok || got != test.want {
+			t.Errorf("%q parses %q as %+v, %v, want %+v", test.pattern, file, got, ok, test.want)
+		}
+	}
+}
+
+func TestSchemeParseRejects(t *testing.T) {
+	scheme, err := compileScheme(defaultFileNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rel := range []string{
+		"notes.md",
+		"12-2024-01-17-my_memo.pdf",
+		"x12-2024-01-17-my_memo.md",
+		"12-2024-1-17-my_memo.md",
+		"12-2024-01-17-.md",
+	} {
+		if name, ok := scheme.parse(rel); ok {
+			t.Errorf("parse(%q) = %+v, want no match", rel, name)
+		}
+	}
+}
+
+// Memos named without {n} keep the number in their front matter when
+// others come and go
+func TestReadMemosStoredNumbers(t *testing.T) {
+	dir := t.TempDir()
+	memoDir := filepath.Join(dir, "memo")
+	config := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(config, []byte("memodir = \""+memoDir+"\"\nfilenames = \"{id}-{slug}.md\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GMEMOCONF", config)
+
+	files := map[string]string{
+		"20240101000000-zeta.md":   "# Zeta\n",
+		"20240102000000-second.md": "---\nnumber: 3\n---\n# Second\n",
+		"20240103000000-copy.md":   "---\nnumber: 3\n---\n# Copy\n",
+		"20240104000000-first.md":  "---\nnumber: 1\n---\n# First\n",
+		"4-2023-12-01-old.md":      "# Old\n",
+	}
+	if err := os.MkdirAll(memoDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(memoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []struct {
+		number int
+		file   string
+	}{
+		{1, "20240104000000-first.md"},
+		// The free numbers go in the order of the names, to the memos
+		// without one and the copy of a taken one
+		{2, "20240101000000-zeta.md"},
+		{3, "20240102000000-second.md"},
+		{4, "4-2023-12-01-old.md"},
+		{5, "20240103000000-copy.md"},
+	}
+
+	memos := readMemos(memoDir)
+	if len(memos) != len(want) {
+		t.Fatalf("readMemos found %d memos, want %d", len(memos), len(want))
+	}
+	for i, memo := range memos {
+		if memo.Number != want[i].number || filepath.Base(memo.Path) != want[i].file {
+			t.Errorf("memo %d is %d %s, want %d %s", i, memo.Number, filepath.Base(memo.Path), want[i].number, want[i].file)
+		}
+	}
+
+	if got := numberMemo("# New\n"); got != "---\nnumber: 6\n---\n# New\n" {
+		t.Errorf("numberMemo gives %q", got)
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,30 +17,55 @@ var newCmd = &cobra.Command{
 	Short: "Add a new memo",
 	Long:  `Add something memorable to your collection of memos`,
 	Run: func(cmd *cobra.Command, args []string) {
-		title()
+		folder, _ := cmd.Flags().GetString("folder")
+		folder, err := cleanFolder(folder)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		formatName, _ := cmd.Flags().GetString("format")
+		if formatName == "" {
+			formatName = getKeyValue("Format").(string)
+		}
+		format, err := findFormat(formatName)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		title(folder, format)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(newCmd)
+	newCmd.Flags().StringP("folder", "f", "", "Folder inside the memo directory to put the memo in")
+	newCmd.Flags().String("format", "", "Format of the memo ("+strings.Join(formatNames(), ", ")+")")
 }
 
-func title() {
+func title(folder string, format memoFormat) {
 	var title string
 	huh.NewInput().
 		Title("Memo Title: ").
 		Value(&title).Run()
 
 	// Pass the title to createFileName and OpenEditor functions
-	fileName := createFileName(title)
+	fileName, err := createFileName(title, folder, format)
+	if err != nil {
+		log.Fatal(err)
+	}
 	exitStatus := openEditor(fileName, title)
 	if exitStatus == nil {
 		commitMsg := fmt.Sprintf("[New]: %s", title)
-		commit(commitMsg, fileName)
+		if err := commit(commitMsg, fileName); err != nil {
+			log.Fatal(err)
+		}
 	}
 }
 
-func createFileName(title string) string {
+// How many names createFileName tries before giving up
+const maxNameAttempts = 100
+
+func createFileName(title string, folder string, format memoFormat) (string, error) {
 
 	// Convert from an interface (or 'any') to string
 	memoDir, err := strconv.Unquote(strconv.Quote(getKeyValue("MemoDir").(string)))
@@ -50,47 +73,114 @@ func createFileName(title string) string {
 		log.Fatalf("Error converting MemoDir to string: %v", err)
 	}
 
-	isMemoDirPresent := DirectoryExists(memoDir)
+	targetDir := filepath.Join(memoDir, folder)
+	if !DirectoryExists(targetDir) {
+		os.MkdirAll(targetDir, 0700)
+	}
+
+	// Memo numbers are unique across all the folders, readMemos
+	// returns them sorted so the last one is the maximum
+	maxNumber := 0
+	if memos := readMemos(memoDir); len(memos) > 0 {
+		maxNumber = memos[len(memos)-1].Number
+	}
+
+	// Increment the maximum number for the next file
+	nextNumber := maxNumber + 1
+
+	scheme := memoSchemes()[0]
+	now := time.Now()
+	slug := slugify(title)
+
+	// Format the new file name. A memo with the same name is possible
+	// when the scheme relies on the slug, or on {id} for memos made in the
+	// same second. Without {slug} the next number and second are tried,
+	// a suffix would make names the scheme can't read back.
+	newFileName := filepath.Join(targetDir, scheme.render(nextNumber, now, slug, format))
+	for i := 2; FileExists(newFileName); i++ {
+		if i > maxNameAttempts {
+			return "", fmt.Errorf("couldn't find a free file name for %q", title)
+		}
+		if strings.Contains(scheme.pattern, "{slug}") {
+			newFileName = filepath.Join(targetDir, scheme.render(nextNumber, now, fmt.Sprintf("%s_%d", slug, i), format))
+			continue
+		}
+		nextNumber++
+		now = now.Add(time.Second)
+		newFileName = filepath.Join(targetDir, scheme.render(nextNumber, now, slug, format))
+	}
 
-	if !isMemoDirPresent {
-		os.MkdirAll(memoDir, 0700)
+	// The scheme can put memos in folders of their own ({yyyy}/...)
+	if err := os.MkdirAll(filepath.Dir(newFileName), 0700); err != nil {
+		return "", err
 	}
-	// Read existing files in the memo directory
-	files, err := os.ReadDir(memoDir)
+
+	return filepath.Join("/", newFileName), nil
+}
+
+// saveNewMemo creates a memo without the editor, the way `memo new`
+// does. An empty content gets the template or heading of a new memo.
+func saveNewMemo(title string, folder string, format memoFormat, content string) (string, error) {
+	fileName, err := createFileName(title, folder, format)
 	if err != nil {
-		log.Fatalf("Error reading %s with error %v", memoDir, err)
+		return "", err
 	}
+	if content == "" {
+		content = newMemoContent(title, format)
+	}
+	content = numberMemo(content)
 
-	// Extract and store numerical parts in a slice
-	var numbers []int
-	re := regexp.MustCompile(`^(\d+)-`)
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		return "", err
+	}
 
-	for _, file := range files {
-		match := re.FindStringSubmatch(file.Name())
-		if len(match) > 1 {
-			num, _ := strconv.Atoi(match[1])
-			numbers = append(numbers, num)
-		}
+	commitMsg := fmt.Sprintf("[New]: %s", title)
+	if err := commit(commitMsg, fileName); err != nil {
+		return fileName, err
 	}
 
-	// Sort numerical parts in ascending order
-	sort.Ints(numbers)
+	return fileName, nil
+}
 
-	// Find the maximum number
-	maxNumber := 0
-	if len(numbers) > 0 {
-		maxNumber = numbers[len(numbers)-1]
+// numberMemo puts the number of a new memo in its front matter when the
+// file name scheme has no {n}, otherwise the memo would be numbered by
+// its place among the others and change number when they do.
+func numberMemo(content string) string {
+	if memoSchemes()[0].hasNumber() {
+		return content
 	}
 
-	// Increment the maximum number for the next file
-	nextNumber := maxNumber + 1
+	next := 1
+	if memos := readMemos(getKeyValue("MemoDir").(string)); len(memos) > 0 {
+		next = memos[len(memos)-1].Number + 1
+	}
+
+	return setFrontMatter(content, "number", []string{strconv.Itoa(next)})
+}
+
+// newMemoContent is what a new memo starts with, either the configured
+// template or just the title as a heading.
+func newMemoContent(title string, format memoFormat) string {
+	templateFile := getKeyValue("Template").(string)
+	if templateFile == "" {
+		return format.heading(title)
+	}
 
-	// Format the current date
-	formattedDate := time.Now().Format("2006-01-02")
+	// Relative templates live next to the config file
+	if !filepath.IsAbs(templateFile) {
+		templateFile = filepath.Join(getKeyValue("configDir").(string), templateFile)
+	}
+
+	content, err := os.ReadFile(templateFile)
+	if err != nil {
+		log.Fatalf("Couldn't read the template %s: %v", templateFile, err)
+	}
 
-	sanitizedTitle := regexp.MustCompile(`[^a-zA-Z0-9]+`).ReplaceAllString(title, "_")
-	// Format the new file name
-	newFileName := fmt.Sprintf("%d-%s-%s.md", nextNumber, formattedDate, strings.ToLower(sanitizedTitle))
+	replacer := strings.NewReplacer(
+		"{title}", title,
+		"{date}", time.Now().Format("2006-01-02"),
+		"{notebook}", getKeyValue("Notebook").(string),
+	)
 
-	return filepath.Join("/", memoDir, newFileName)
+	return replacer.Replace(string(content))
 }
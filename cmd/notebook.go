@@ -0,0 +1,195 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+// The top level MemoDir in the config is known by this name
+const defaultNotebook = "default"
+
+// Set by the global --notebook flag
+var notebookName string
+
+var notebookCmd = &cobra.Command{
+	Use:   "notebook",
+	Short: "Manage your notebooks",
+	Long:  `Keep separate collections of memos (work, personal, projects) in their own notebooks`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listNotebooks()
+	},
+}
+
+var notebookAddCmd = &cobra.Command{
+	Use:   "add <name> <directory>",
+	Short: "Add a new notebook",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		nb := Notebook{Name: args[0]}
+
+		dir, err := filepath.Abs(args[1])
+		if err != nil {
+			log.Fatalf("Couldn't resolve %s: %v", args[1], err)
+		}
+		nb.MemoDir = dir
+
+		if cmd.Flag("git").Changed {
+			git, _ := cmd.Flags().GetBool("git")
+			nb.Git = &git
+		}
+		nb.Template, _ = cmd.Flags().GetString("template")
+		nb.Theme, _ = cmd.Flags().GetString("theme")
+
+		addNotebook(nb)
+	},
+}
+
+var notebookListCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List the notebooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		listNotebooks()
+	},
+}
+
+var notebookUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Switch the default notebook",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		useNotebook(args[0])
+	},
+}
+
+var notebookRemoveCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove"},
+	Short:   "Remove a notebook from the config, the memos are left as is",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		removeNotebook(args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(notebookCmd)
+	notebookCmd.AddCommand(notebookAddCmd, notebookListCmd, notebookUseCmd, notebookRemoveCmd)
+
+	notebookAddCmd.Flags().Bool("git", false, "Commit the memos in this notebook to git")
+	notebookAddCmd.Flags().String("template", "", "Template file used for new memos")
+	notebookAddCmd.Flags().String("theme", "", "Theme used when viewing memos in the terminal")
+}
+
+func findNotebook(conf Config, name string) (Notebook, bool) {
+	for _, nb := range conf.Notebooks {
+		if nb.Name == name {
+			return nb, true
+		}
+	}
+
+	return Notebook{}, false
+}
+
+// allNotebooks returns every notebook including the default one
+func allNotebooks() []Notebook {
+	conf := readConfig(getKeyValue("configFile").(string))
+	notebooks := []Notebook{{Name: defaultNotebook, MemoDir: conf.MemoDir}}
+
+	return append(notebooks, conf.Notebooks...)
+}
+
+func addNotebook(nb Notebook) {
+	configFile := getKeyValue("configFile").(string)
+	conf := readConfig(configFile)
+
+	if nb.Name == defaultNotebook {
+		log.Fatalf("%q is reserved for the top level memo directory", defaultNotebook)
+	}
+	if _, ok := findNotebook(conf, nb.Name); ok {
+		log.Fatalf("A notebook named %q already exists", nb.Name)
+	}
+
+	if !DirectoryExists(nb.MemoDir) {
+		if err := os.MkdirAll(nb.MemoDir, 0700); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	conf.Notebooks = append(conf.Notebooks, nb)
+	if err := saveConfigToFile(configFile, conf); err != nil {
+		log.Fatalf("Couldn't save the config: %v", err)
+	}
+
+	fmt.Printf("Added notebook %s (%s)\n", nb.Name, nb.MemoDir)
+}
+
+func useNotebook(name string) {
+	configFile := getKeyValue("configFile").(string)
+	conf := readConfig(configFile)
+
+	if name != defaultNotebook {
+		if _, ok := findNotebook(conf, name); !ok {
+			log.Fatalf("There is no notebook named %q", name)
+		}
+	}
+
+	conf.Notebook = name
+	if err := saveConfigToFile(configFile, conf); err != nil {
+		log.Fatalf("Couldn't save the config: %v", err)
+	}
+
+	fmt.Printf("Now using notebook %s\n", name)
+}
+
+func removeNotebook(name string) {
+	configFile := getKeyValue("configFile").(string)
+	conf := readConfig(configFile)
+
+	if _, ok := findNotebook(conf, name); !ok {
+		log.Fatalf("There is no notebook named %q", name)
+	}
+
+	var kept []Notebook
+	for _, nb := range conf.Notebooks {
+		if nb.Name != name {
+			kept = append(kept, nb)
+		}
+	}
+	conf.Notebooks = kept
+
+	if conf.Notebook == name {
+		conf.Notebook = ""
+	}
+
+	if err := saveConfigToFile(configFile, conf); err != nil {
+		log.Fatalf("Couldn't save the config: %v", err)
+	}
+
+	fmt.Printf("Removed notebook %s, the memos are still in place\n", name)
+}
+
+func listNotebooks() {
+	active := getKeyValue("Notebook").(string)
+
+	activeStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4"))
+	dirStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	for _, nb := range allNotebooks() {
+		marker := "  "
+		name := nb.Name
+		if nb.Name == active {
+			marker = "* "
+			name = activeStyle.Render(name)
+		}
+		fmt.Printf("%s%s %s (%d memos)\n", marker, name, dirStyle.Render(nb.MemoDir), len(readMemos(nb.MemoDir)))
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Keeps proxies from closing an idle event stream
+const keepAliveInterval = 30 * time.Second
+
+// reloadHub tells the open pages to reload through Server-Sent Events
+// on /events whenever broadcast is called.
+type reloadHub struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+	done    chan struct{}
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{clients: map[chan struct{}]bool{}, done: make(chan struct{})}
+}
+
+// close ends the streams, they would otherwise keep the server from
+// shutting down
+func (h *reloadHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	select {
+	case <-h.done:
+	default:
+		close(h.done)
+	}
+}
+
+func (h *reloadHub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		// A client with a reload pending doesn't need another
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *reloadHub) subscribe() chan struct{} {
+	client := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	h.clients[client] = true
+	h.mu.Unlock()
+
+	return client
+}
+
+func (h *reloadHub) unsubscribe(client chan struct{}) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+}
+
+func (h *reloadHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := h.subscribe()
+	defer h.unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, "retry: 2000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-client:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		}
+		flusher.Flush()
+	}
+}
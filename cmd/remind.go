@@ -0,0 +1,503 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// How reminders are written in the front matter, `remind: ...`
+	remindLayout = "2006-01-02 15:04"
+	// Reminders already sent, one per line in the config directory
+	remindedFile = "reminded"
+	// The hour of reminders given only a day, and of due tasks
+	defaultRemindHour = 9
+)
+
+// reminder is a `remind:` time of a memo or the due date of a task in
+// it, Line is the line of the task
+type reminder struct {
+	Memo memoFile
+	At   time.Time
+	Text string
+	Line int
+}
+
+var remindCmd = &cobra.Command{
+	Use:   "remind <memo> <when>",
+	Short: "Get reminded of a memo",
+	Long: `Get reminded of a memo at some time, like:
+
+  memo remind 3 tomorrow 9am
+  memo remind 3 "in 2 hours"
+  memo remind 3 friday
+  memo remind 3 2026-11-01 14:30
+
+The reminder is kept in the front matter of the memo. memo remind check
+or memo daemon send it when it's due.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clearAll, _ := cmd.Flags().GetBool("clear")
+		if !clearAll && len(args) < 2 {
+			log.Fatal("When should memo remind you? Like: memo remind 3 tomorrow 9am")
+		}
+
+		filename := matchMemo(args[0])
+		if filename == "" {
+			log.Fatalf("[%s]: Couldn't match any memo", args[0])
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fm, _ := splitFrontMatter(string(content))
+		var times []string
+		message := fmt.Sprintf("[Remind]: No reminders for %s", getFileTitle(filename))
+		if !clearAll {
+			at, err := parseWhen(strings.Join(args[1:], " "), time.Now())
+			if err != nil {
+				log.Fatal(err)
+			}
+			if at.Before(time.Now()) {
+				log.Fatalf("%s is already past", at.Format(remindLayout))
+			}
+			times = append(fm["remind"], at.Format(remindLayout))
+			message = fmt.Sprintf("[Remind]: %s at %s", getFileTitle(filename), at.Format(remindLayout))
+		}
+
+		if err := os.WriteFile(filename, []byte(setFrontMatter(string(content), "remind", times)), 0644); err != nil {
+			log.Fatal(err)
+		}
+		if err := commit(message, filename); err != nil {
+			log.Fatal(err)
+		}
+
+		if clearAll {
+			fmt.Println("Removed the reminders of", strings.TrimSpace(getFileTitle(filename)))
+			return
+		}
+		fmt.Printf("Reminding you on %s\n", times[len(times)-1])
+	},
+}
+
+var remindCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Send the reminders that are due",
+	Long: `Send the reminders that are due and weren't sent yet, as desktop
+notifications with notify-send or printed when it isn't there. Meant to
+run from cron:
+
+  * * * * * memo remind check`,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		stdout, _ := cmd.Flags().GetBool("stdout")
+
+		if err := checkReminders(reminderNotebooks(all), stdout, time.Now()); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var remindersCmd = &cobra.Command{
+	Use:   "reminders",
+	Short: "List the upcoming and overdue reminders",
+	Long:  `List the reminders which weren't sent yet and the ones to come, with the due dates of open tasks`,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+
+		sent := readReminded()
+		now := time.Now()
+		found := false
+		for _, nb := range reminderNotebooks(all) {
+			var pending []reminder
+			for _, r := range allReminders(nb.MemoDir) {
+				if r.At.After(now) || !sent[reminderKey(nb.MemoDir, r)] {
+					pending = append(pending, r)
+				}
+			}
+			if len(pending) == 0 {
+				continue
+			}
+			if all {
+				fmt.Printf("[%s]\n", nb.Name)
+			}
+			printReminders(pending, now)
+			found = true
+		}
+
+		if !found {
+			fmt.Println("No reminders")
+		}
+	},
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keep sending the reminders when they're due",
+	Long:  `Check the reminders every minute, like memo remind check from cron, until stopped`,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		stdout, _ := cmd.Flags().GetBool("stdout")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval < time.Second {
+			log.Fatal("The interval has to be at least a second")
+		}
+
+		log.Printf("Checking the reminders every %s", interval)
+		for {
+			if err := checkReminders(reminderNotebooks(all), stdout, time.Now()); err != nil {
+				log.Print(err)
+			}
+			time.Sleep(interval)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(remindCmd)
+	rootCmd.AddCommand(remindersCmd)
+	rootCmd.AddCommand(daemonCmd)
+	remindCmd.AddCommand(remindCheckCmd)
+
+	remindCmd.Flags().Bool("clear", false, "Remove the reminders of the memo")
+	for _, cmd := range []*cobra.Command{remindCheckCmd, daemonCmd} {
+		cmd.Flags().BoolP("all", "a", false, "Send the reminders of every notebook")
+		cmd.Flags().Bool("stdout", false, "Print the reminders instead of notifying")
+	}
+	remindersCmd.Flags().BoolP("all", "a", false, "List the reminders of every notebook")
+	daemonCmd.Flags().Duration("interval", time.Minute, "How often to check")
+}
+
+var (
+	relativeWhen = regexp.MustCompile(`^in (\d+|an?) ?(m|mins?|minutes?|h|hours?|d|days?|w|weeks?)$`)
+	clockTime    = regexp.MustCompile(`^(\d{1,2})(?:[:.](\d{2}))? ?(am|pm)?$`)
+)
+
+var namedTimes = map[string]int{"noon": 12, "midnight": 0, "morning": 9, "afternoon": 14, "evening": 18, "tonight": 20}
+
+// parseWhen reads the time of a reminder, a date with an optional time
+// ("2026-11-01 14:30"), "in 2 hours" or words like "tomorrow 9am" and
+// "next friday at noon"
+func parseWhen(text string, now time.Time) (time.Time, error) {
+	text = strings.Join(strings.Fields(text), " ")
+
+	// The layouts want their T and Z as they are
+	for _, layout := range []string{time.RFC3339, remindLayout, "2006-01-02T15:04"} {
+		if at, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return at, nil
+		}
+	}
+	text = strings.ToLower(text)
+
+	if m := relativeWhen.FindStringSubmatch(text); m != nil {
+		count, err := strconv.Atoi(m[1])
+		if err != nil {
+			count = 1
+		}
+		unit := map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[m[2][0]]
+		return now.Add(time.Duration(count) * unit), nil
+	}
+
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	var date time.Time
+	hour, minute := -1, 0
+
+	words := strings.Fields(strings.NewReplacer(" am", "am", " pm", "pm").Replace(text))
+	for _, word := range words {
+		if weekday, ok := parseWeekday(word); ok {
+			days := (int(weekday) - int(today.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			date = today.AddDate(0, 0, days)
+			continue
+		}
+		if named, ok := namedTimes[word]; ok {
+			hour, minute = named, 0
+			if word == "tonight" && date.IsZero() {
+				date = today
+			}
+			continue
+		}
+		if m := clockTime.FindStringSubmatch(word); m != nil && (m[2] != "" || m[3] != "") {
+			h, _ := strconv.Atoi(m[1])
+			minute, _ = strconv.Atoi(m[2])
+			switch {
+			case m[3] == "pm" && h < 12:
+				h += 12
+			case m[3] == "am" && h == 12:
+				h = 0
+			}
+			if h > 23 || minute > 59 {
+				return time.Time{}, fmt.Errorf("%q isn't a time of the day", word)
+			}
+			hour = h
+			continue
+		}
+		if parsed, err := time.ParseInLocation(time.DateOnly, word, time.Local); err == nil {
+			date = parsed
+			continue
+		}
+
+		switch word {
+		case "at", "on", "next", "this":
+		case "today":
+			date = today
+		case "tomorrow":
+			date = today.AddDate(0, 0, 1)
+		default:
+			return time.Time{}, fmt.Errorf("couldn't read %q as a time, try \"tomorrow 9am\", \"in 2 hours\" or \"2026-11-01 14:30\"", text)
+		}
+	}
+
+	at := func(date time.Time) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, time.Local)
+	}
+	switch {
+	case date.IsZero() && hour < 0:
+		return time.Time{}, fmt.Errorf("%q says neither a day nor a time", text)
+	case date.IsZero():
+		// A time alone is the next one to come
+		date = today
+		if !at(today).After(now) {
+			date = today.AddDate(0, 0, 1)
+		}
+	case hour < 0:
+		hour = defaultRemindHour
+	}
+
+	return at(date), nil
+}
+
+func parseWeekday(word string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if word == name || word == name[:3] || (len(word) > 3 && strings.HasPrefix(name, word)) {
+			return day, true
+		}
+	}
+
+	return 0, false
+}
+
+// memoReminders are the reminders in the front matter of a memo and
+// the due dates of its open tasks
+func memoReminders(memo memoFile, content []byte) []reminder {
+	fm, _ := splitFrontMatter(string(content))
+	title := strings.TrimSpace(memoTitle(memo.Path))
+
+	var reminders []reminder
+	for _, value := range fm["remind"] {
+		for _, layout := range append([]string{remindLayout}, frontMatterDates...) {
+			if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				if layout == time.DateOnly {
+					at = at.Add(defaultRemindHour * time.Hour)
+				}
+				reminders = append(reminders, reminder{Memo: memo, At: at, Text: title})
+				break
+			}
+		}
+	}
+
+	for _, task := range readTasks(memo, content) {
+		if !task.Done && !task.Due.IsZero() {
+			reminders = append(reminders, reminder{
+				Memo: memo,
+				At:   task.Due.Add(defaultRemindHour * time.Hour),
+				Text: task.Text,
+				Line: task.Line,
+			})
+		}
+	}
+
+	return reminders
+}
+
+// allReminders are the reminders of every memo, the first due first
+func allReminders(memoDir string) []reminder {
+	var reminders []reminder
+	for _, memo := range readMemos(memoDir) {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		reminders = append(reminders, memoReminders(memo, content)...)
+	}
+
+	sort.SliceStable(reminders, func(i, j int) bool { return reminders[i].At.Before(reminders[j].At) })
+	return reminders
+}
+
+// reminderNotebooks are the current notebook, or all of them
+func reminderNotebooks(all bool) []Notebook {
+	if all {
+		return allNotebooks()
+	}
+
+	return []Notebook{{Name: getKeyValue("Notebook").(string), MemoDir: getKeyValue("MemoDir").(string)}}
+}
+
+// reminderKey names a reminder in the reminded file, it changes when
+// the reminder is moved to another time. Tasks go by their text, lines
+// move whenever something is written above them. The memo directory is
+// quoted, a path can have any character in it.
+func reminderKey(memoDir string, r reminder) string {
+	if r.Line == 0 {
+		return fmt.Sprintf("%q#%d:0@%s", memoDir, r.Memo.Number, r.At.Format(time.RFC3339))
+	}
+
+	sum := sha256.Sum256([]byte(r.Text))
+	return fmt.Sprintf("%q#%d:%x@%s", memoDir, r.Memo.Number, sum[:6], r.At.Format(time.RFC3339))
+}
+
+// reminderDir is the memo directory a reminderKey was made for
+func reminderDir(key string) (string, bool) {
+	quoted, err := strconv.QuotedPrefix(key)
+	if err != nil {
+		return "", false
+	}
+	memoDir, err := strconv.Unquote(quoted)
+
+	return memoDir, err == nil
+}
+
+func readReminded() map[string]bool {
+	sent := map[string]bool{}
+	file, err := os.Open(filepath.Join(getKeyValue("configDir").(string), remindedFile))
+	if err != nil {
+		return sent
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			sent[line] = true
+		}
+	}
+
+	return sent
+}
+
+func saveReminded(sent map[string]bool) error {
+	keys := make([]string, 0, len(sent))
+	for key := range sent {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	content := strings.Join(keys, "\n")
+	if content != "" {
+		content += "\n"
+	}
+
+	return os.WriteFile(filepath.Join(getKeyValue("configDir").(string), remindedFile), []byte(content), 0600)
+}
+
+// checkReminders sends the reminders due by now which weren't sent
+// yet. The ones no longer in any memo are forgotten.
+func checkReminders(notebooks []Notebook, stdout bool, now time.Time) error {
+	sent := readReminded()
+	current := map[string]bool{}
+	checked := map[string]bool{}
+
+	for _, nb := range notebooks {
+		checked[nb.MemoDir] = true
+		for _, r := range allReminders(nb.MemoDir) {
+			key := reminderKey(nb.MemoDir, r)
+			current[key] = true
+			if r.At.After(now) || sent[key] {
+				continue
+			}
+
+			if err := notify(r, stdout); err != nil {
+				// Don't send the ones before it again next time
+				if saveErr := saveReminded(sent); saveErr != nil {
+					log.Print(saveErr)
+				}
+				return err
+			}
+			sent[key] = true
+		}
+	}
+
+	// Only the notebooks looked at can tell which reminders are gone,
+	// keys memo can't read anymore go too
+	for key := range sent {
+		memoDir, ok := reminderDir(key)
+		if !ok || checked[memoDir] && !current[key] {
+			delete(sent, key)
+		}
+	}
+
+	return saveReminded(sent)
+}
+
+// notify shows a reminder on the desktop, or prints it when that
+// isn't possible
+func notify(r reminder, stdout bool) error {
+	title := "Memo: " + r.Text
+	hint := fmt.Sprintf("memo view %d", r.Memo.Number)
+	if r.Line > 0 {
+		title = "Memo: task due"
+		hint = fmt.Sprintf("memo todo done %d:%d", r.Memo.Number, r.Line)
+	}
+
+	if !stdout {
+		if path, err := exec.LookPath("notify-send"); err == nil {
+			body := hint
+			if r.Line > 0 {
+				body = r.Text + "\n" + hint
+			}
+			if err := exec.Command(path, "--app-name=memo", title, body).Run(); err == nil {
+				return nil
+			}
+		}
+	}
+
+	text := title
+	if r.Line > 0 {
+		text += ": " + r.Text
+	}
+	_, err := fmt.Printf("%s %s (%s)\n", r.At.Format(remindLayout), text, hint)
+	return err
+}
+
+func printReminders(reminders []reminder, now time.Time) {
+	refStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+	lateStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87"))
+	memoStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	for _, r := range reminders {
+		at := r.At.Format("Mon " + remindLayout)
+		if !r.At.After(now) {
+			at = lateStyle.Render(at + " overdue")
+		}
+
+		ref := strconv.Itoa(r.Memo.Number)
+		text := r.Text
+		if r.Line > 0 {
+			ref = fmt.Sprintf("%d:%d", r.Memo.Number, r.Line)
+			text += memoStyle.Render("  " + strings.TrimSpace(getFileTitle(r.Memo.Path)))
+		}
+		fmt.Printf("%s %s  %s\n", refStyle.Render(fmt.Sprintf("%8s", ref)), at, text)
+	}
+}
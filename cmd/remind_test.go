@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2026, 10, 14, 10, 0, 0, 0, time.Local)
+	day := func(d int, hour int, minute int) time.Time {
+		return time.Date(2026, 10, d, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		text string
+		want time.Time
+	}{
+		{"2026-11-01 14:30", time.Date(2026, 11, 1, 14, 30, 0, 0, time.Local)},
+		{"2026-11-01T14:30", time.Date(2026, 11, 1, 14, 30, 0, 0, time.Local)},
+		{"2026-11-01T14:30:00Z", time.Date(2026, 11, 1, 14, 30, 0, 0, time.UTC)},
+		{"2026-11-01", time.Date(2026, 11, 1, defaultRemindHour, 0, 0, 0, time.Local)},
+		{"2026-11-01 3pm", time.Date(2026, 11, 1, 15, 0, 0, 0, time.Local)},
+		{"in 2 hours", now.Add(2 * time.Hour)},
+		{"in 30m", now.Add(30 * time.Minute)},
+		{"in a day", now.Add(24 * time.Hour)},
+		{"In 1 Week", now.Add(7 * 24 * time.Hour)},
+		{"tomorrow 9am", day(15, 9, 0)},
+		{"tomorrow  at 9 AM", day(15, 9, 0)},
+		{"12am tomorrow", day(15, 0, 0)},
+		{"next friday at noon", day(16, 12, 0)},
+		{"fri 18:15", day(16, 18, 15)},
+		// The same weekday is the one of next week
+		{"wednesday", day(21, defaultRemindHour, 0)},
+		{"today 11.30", day(14, 11, 30)},
+		{"tonight", day(14, 20, 0)},
+		{"12pm", day(14, 12, 0)},
+		// A time gone by today is the one of tomorrow
+		{"9am", day(15, 9, 0)},
+		{"10:00", day(15, 10, 0)},
+	}
+
+	for _, test := range tests {
+		got, err := parseWhen(test.text, now)
+		if err != nil || !got.Equal(test.want) {
+			t.Errorf("parseWhen(%q) = %v, %v, want %v", test.text, got, err, test.want)
+		}
+	}
+
+	for _, text := range []string{"", "someday", "at", "25:00", "9:75", "tomorrow or so", "in many days", "2026-13-01"} {
+		if got, err := parseWhen(text, now); err == nil {
+			t.Errorf("parseWhen(%q) = %v, want an error", text, got)
+		}
+	}
+}
+
+func TestReminderKey(t *testing.T) {
+	at := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+	task := reminder{Memo: memoFile{memoName: memoName{Number: 3}}, At: at, Text: "Pay rent", Line: 7}
+
+	tests := []struct {
+		memoDir string
+		r       reminder
+	}{
+		{"/home/me/memo", reminder{Memo: task.Memo, At: at, Text: "Title"}},
+		{"/home/me/memo", task},
+		{"/home/me/C#/memo", task},
+		{"/tmp/new\nline", task},
+		{`/tmp/"quoted"`, task},
+	}
+
+	for _, test := range tests {
+		key := reminderKey(test.memoDir, test.r)
+		if strings.Contains(key, "\n") {
+			t.Errorf("reminderKey(%q) = %q, the reminded file has one key per line", test.memoDir, key)
+		}
+		if memoDir, ok := reminderDir(key); !ok || memoDir != test.memoDir {
+			t.Errorf("reminderDir(%q) = %q, %v, want %q", key, memoDir, ok, test.memoDir)
+		}
+	}
+
+	// Tasks go by their text, not by the line they are on
+	moved := task
+	moved.Line = 12
+	if reminderKey("/m", task) != reminderKey("/m", moved) {
+		t.Error("a task moved to another line has another key")
+	}
+	renamed := task
+	renamed.Text = "Pay the rent"
+	if reminderKey("/m", task) == reminderKey("/m", renamed) {
+		t.Error("two tasks have the same key")
+	}
+
+	if _, ok := reminderDir("/m#3:0@2026-11-01T09:00:00Z"); ok {
+		t.Error("a key from before the memo directory was quoted is read")
+	}
+}
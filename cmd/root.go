@@ -37,4 +37,5 @@ func Execute() {
 
 func init() {
 	rootCmd.Root().CompletionOptions.DisableDefaultCmd = true
+	rootCmd.PersistentFlags().StringVarP(&notebookName, "notebook", "n", "", "Notebook to use instead of the configured one")
 }
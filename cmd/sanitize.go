@@ -0,0 +1,105 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// How much of the HTML in memos reaches the browser, set with
+// `sanitize` in the config
+const (
+	// Markdown only, HTML written in memos is dropped
+	sanitizeStrict = "strict"
+	// Formatting HTML is kept, scripts, styles, forms... are not
+	sanitizeBasic = "allow-basic-html"
+	// Everything as written, only for memos nobody else can change
+	sanitizeTrusted = "trusted"
+)
+
+// sanitizeMode is the configured policy, allow-basic-html by default
+func sanitizeMode() (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(getKeyValue("Sanitize").(string)))
+	switch mode {
+	case "":
+		return sanitizeBasic, nil
+	case sanitizeStrict, sanitizeBasic, sanitizeTrusted:
+		return mode, nil
+	}
+
+	return sanitizeStrict, fmt.Errorf("unknown sanitize policy %q, use %s, %s or %s", mode, sanitizeStrict, sanitizeBasic, sanitizeTrusted)
+}
+
+// memoPolicy cleans the HTML rendered from memos
+var memoPolicy = sync.OnceValue(func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	// Code blocks name their language and footnotes get classes
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w -]+$`)).OnElements("code", "pre", "span", "div", "sup", "li", "a")
+	// Headings keep their ids in any script, the table of contents and
+	// the anchors link to them
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\p{L}\p{M}\p{N}_.:-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	// Checkboxes of tasks
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^task$`)).OnElements("input")
+	p.AllowAttrs("data-line").Matching(regexp.MustCompile(`^[0-9]+$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	// Diagrams are SVG images inline
+	p.AllowDataURIImages()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^diagram$`)).OnElements("figure")
+	p.AllowElements("figcaption")
+	// Formulas are MathML, most of it comes without attributes
+	p.AllowNoAttrs().OnElements("math", "semantics", "annotation", "mrow", "mi", "mn", "mo", "mtext", "mspace",
+		"mfrac", "msqrt", "mroot", "msup", "msub", "msubsup", "mover", "munder", "munderover", "mtable", "mtr", "mtd")
+	p.AllowAttrs("display").Matching(regexp.MustCompile(`^(block|inline)$`)).OnElements("math")
+	p.AllowAttrs("mathvariant").Matching(regexp.MustCompile(`^[a-z-]+$`)).OnElements("mi", "mn", "mo", "mtext")
+	p.AllowAttrs("accent").Matching(regexp.MustCompile(`^(true|false)$`)).OnElements("mover", "munder")
+	p.AllowAttrs("width").Matching(regexp.MustCompile(`^[0-9.]+em$`)).OnElements("mspace")
+	p.AllowAttrs("linethickness").Matching(regexp.MustCompile(`^0$`)).OnElements("mfrac")
+	p.AllowAttrs("encoding").Matching(regexp.MustCompile(`^application/x-tex$`)).OnElements("annotation")
+
+	return p
+})
+
+// contentSecurityPolicy keeps whatever gets past the sanitiser from
+// running scripts, only the ones served by memo itself are allowed.
+// Trusted memos may use inline scripts.
+func contentSecurityPolicy() string {
+	scripts := "'self'"
+	if mode, _ := sanitizeMode(); mode == sanitizeTrusted {
+		scripts += " 'unsafe-inline'"
+	}
+
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src " + scripts,
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com",
+		"font-src 'self' https://fonts.gstatic.com",
+		"img-src 'self' data: https:",
+		"media-src 'self' https:",
+		"object-src 'none'",
+		"base-uri 'none'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// secureHeaders adds the Content-Security-Policy to every response
+func secureHeaders(next http.Handler) http.Handler {
+	csp := contentSecurityPolicy()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", csp)
+		// Attachments are served as what their extension says
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "same-origin")
+		next.ServeHTTP(w, r)
+	})
+}
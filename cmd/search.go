@@ -0,0 +1,148 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search through your memos",
+	Long:  `Search through your memos, every word in the query has to be in the memo for it to match`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := strings.Join(args, " ")
+		all, _ := cmd.Flags().GetBool("all")
+		folder, _ := cmd.Flags().GetString("folder")
+		folder, err := cleanFolder(folder)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		notebooks := []Notebook{{Name: getKeyValue("Notebook").(string), MemoDir: getKeyValue("MemoDir").(string)}}
+		if all {
+			notebooks = allNotebooks()
+		}
+
+		found := false
+		for _, nb := range notebooks {
+			var results []searchResult
+			for _, result := range searchMemos(nb.MemoDir, query) {
+				if inFolder(result.Memo, folder) {
+					results = append(results, result)
+				}
+			}
+			if len(results) == 0 {
+				continue
+			}
+			found = true
+			printSearchResults(nb.Name, query, results, all)
+		}
+
+		if !found {
+			fmt.Printf("No memo matches %q\n", query)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().BoolP("all", "a", false, "Search the memos in every notebook")
+	searchCmd.Flags().StringP("folder", "f", "", "Only search the memos in this folder")
+}
+
+type searchLine struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+type searchResult struct {
+	Memo  memoFile
+	Title string
+	Lines []searchLine
+}
+
+// searchMemos finds the memos in memoDir containing every word in query,
+// the comparison ignores case.
+func searchMemos(memoDir string, query string) []searchResult {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	var results []searchResult
+	for _, memo := range readMemos(memoDir) {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+
+		lower := strings.ToLower(string(content))
+		matchesAll := true
+		for _, word := range words {
+			if !strings.Contains(lower, word) {
+				matchesAll = false
+				break
+			}
+		}
+		if !matchesAll {
+			continue
+		}
+
+		result := searchResult{Memo: memo, Title: strings.TrimSpace(memoTitle(memo.Path))}
+		for i, line := range strings.Split(string(content), "\n") {
+			lowerLine := strings.ToLower(line)
+			for _, word := range words {
+				if strings.Contains(lowerLine, word) {
+					result.Lines = append(result.Lines, searchLine{Number: i + 1, Text: strings.TrimSpace(line)})
+					break
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// queryRegex matches any of the words in query, ignoring case
+func queryRegex(query string) *regexp.Regexp {
+	var quoted []string
+	for _, word := range strings.Fields(query) {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+
+	return regexp.MustCompile(`(?i)(` + strings.Join(quoted, "|") + `)`)
+}
+
+func printSearchResults(notebook string, query string, results []searchResult, showNotebook bool) {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4"))
+	lineStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	matchStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FAFAFA")).Background(lipgloss.Color("#7D56F4"))
+
+	re := queryRegex(query)
+	for _, result := range results {
+		heading := fmt.Sprintf("Memo %d: %s", result.Memo.Number, result.Title)
+		if showNotebook {
+			heading = fmt.Sprintf("[%s] %s", notebook, heading)
+		}
+		fmt.Println(titleStyle.Render(heading))
+
+		for _, line := range result.Lines {
+			text := re.ReplaceAllStringFunc(line.Text, func(match string) string {
+				return matchStyle.Render(match)
+			})
+			fmt.Printf("  %s %s\n", lineStyle.Render(fmt.Sprintf("%4d:", line.Number)), text)
+		}
+		fmt.Println()
+	}
+}
@@ -6,54 +6,126 @@ package cmd
 import (
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 
 	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 )
 
-var memoNumber int
-
 // serveCmd represents the serve command
 var serveCmd = &cobra.Command{
-	Use:   "serve",
+	Use:   "serve [memo]",
 	Short: "View Your Memo in the browser",
 	Long:  `View Your Memo in your favourite broswer!`,
 	Run: func(cmd *cobra.Command, args []string) {
+		addr, err := listenAddress(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		open, _ := cmd.Flags().GetBool("open")
+
+		// A memo given on the command line is just the page to start on
+		openPath := "/"
 		if len(args) > 0 {
-			memoNumber, _ = strconv.Atoi(args[0])
-			mux := http.NewServeMux()
-			mux.HandleFunc("/", displayIndividualFile)
-			log.Print("Server started on http://127.0.0.0:4000")
-			err := http.ListenAndServe(":4000", mux)
-			if err != nil {
-				log.Print(err)
+			memo, ok := memoByPath(matchMemo(args[0]))
+			if !ok {
+				log.Fatalf("[%s]: Couldn't match any memo", args[0])
 			}
-		} else {
-			// Display The Index File
-			displayIndex()
+			openPath = memoURL(memo)
+		}
+
+		// Open pages reload when a memo or the look of the site changes
+		reloads := newReloadHub()
+		go watchChanges([]string{getKeyValue("MemoDir").(string), getKeyValue("StaticFiles").(string)}, reloads.broadcast)
+
+		if _, err := sanitizeMode(); err != nil {
+			log.Fatal(err)
+		}
+		if _, err := highlightStyle(); err != nil {
+			log.Fatal(err)
+		}
+
+		tlsConfig, err := serverTLSConfig(cmd, addr)
+		if err != nil {
+			log.Fatalf("Couldn't set up TLS: %v", err)
 		}
+		auth := newAuthenticator(getKeyValue("configFile").(string))
+		warnInsecure(addr, auth.enabled(), tlsConfig != nil)
+
+		startServer(newRouter(reloads, auth), addr, openPath, open, tlsConfig, reloads.close)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String("addr", "", "Address to listen on, use 0.0.0.0 to allow other machines (default 127.0.0.1)")
+	serveCmd.Flags().IntP("port", "p", 4000, "Port to listen on, 0 picks a free one")
+	serveCmd.Flags().BoolP("open", "o", false, "Open the memos in the browser")
+	serveCmd.Flags().Bool("tls", false, "Serve over HTTPS, with a self-signed certificate unless tlscert and tlskey are set")
+	serveCmd.Flags().String("tls-cert", "", "Certificate file for HTTPS (default tlscert in the config)")
+	serveCmd.Flags().String("tls-key", "", "Key file of the certificate (default tlskey in the config)")
+}
+
+// newRouter sets up every page of the web UI
+func newRouter(reloads *reloadHub, auth *authenticator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", home)
+	mux.HandleFunc("/folder/", home)
+	mux.HandleFunc("/memo/", viewMemo)
+	mux.HandleFunc("/raw/", rawMemo)
+	mux.HandleFunc("/tag/", tagPage)
+	mux.HandleFunc("/search", searchPage)
+	mux.HandleFunc("/calendar", calendarPage)
+	mux.HandleFunc("/calendar.ics", serveICS)
+	mux.HandleFunc("/history/", historyPage)
+	mux.HandleFunc("/attachments/", serveAttachment)
+	mux.HandleFunc("/script.js", serveScript)
+	for kind := range feedTypes {
+		mux.HandleFunc("/feed."+kind, serveFeed)
+	}
+	addEditRoutes(mux)
+	addAPIRoutes(mux)
+	mux.Handle("/events", reloads)
+	mux.HandleFunc("/login", auth.loginPage)
+	mux.HandleFunc("/logout", auth.logout)
+	// Links from before the clean URLs
+	mux.HandleFunc("/view", redirectView)
+
+	return secureHeaders(auth.protect(mux))
 }
 
 type inputData struct {
 	Title       string
+	Breadcrumbs template.HTML
 	Main        template.HTML
 	StyleSheet  template.CSS
+	// Scripts are served from /script.js, inline ones are blocked by
+	// the Content-Security-Policy. Left empty for older base.html files.
 	ScriptSheet template.JS
+	// Who is logged in, empty without users in the config
+	User     string
+	CanWrite bool
+	// Where the site starts, "/" when served and relative to the page
+	// for `memo export html`, which also sets Static
+	Root   string
+	Static bool
+	// Table of contents of a memo, next to it
+	TOC template.HTML
 }
 
 func serveStaticFile(fileType string) string {
@@ -90,109 +162,320 @@ func serveStaticFile(fileType string) string {
 	return fileContent
 }
 
-func home(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		displayCustom404(w, r)
-		return
+// renderPage fills base.html with data for the user sending r
+func renderPage(w http.ResponseWriter, r *http.Request, data inputData) {
+	if user, ok := requestUser(r); ok {
+		data.User = user.Name
+	}
+	data.CanWrite = canWrite(r)
+	data.Root = "/"
+
+	if err := executeBase(w, data); err != nil {
+		log.Print(err.Error())
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
-	homeFiles := filepath.Join(getKeyValue("StaticFiles").(string))
-	baseFile := filepath.Join(homeFiles, "base.html")
+}
+
+// executeBase fills base.html in StaticFiles with data
+func executeBase(w io.Writer, data inputData) error {
+	baseFile := filepath.Join(getKeyValue("StaticFiles").(string), "base.html")
 	ts, err := template.New("base.html").ParseFiles(baseFile)
+	if err != nil {
+		return err
+	}
+
+	if data.Breadcrumbs == "" {
+		data.Breadcrumbs = breadcrumbs("")
+	}
+	data.StyleSheet = template.CSS(serveStaticFile("css") + highlightCSS())
+
+	return ts.Execute(w, data)
+}
 
-	// Now Get the files
+// serveScript serves the scripts in StaticFiles as one file
+func serveScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, serveStaticFile("js"))
+}
+
+func home(w http.ResponseWriter, r *http.Request) {
 	memoDir := getKeyValue("MemoDir").(string)
-	files, _ := os.ReadDir(memoDir)
 
-	var forwardContent string
-	for _, file := range files {
-		if !file.IsDir() {
-			fileTitle := getFileTitle(filepath.Join(memoDir, file.Name()))
-			re := regexp.MustCompile(`^(\d+)-`)
-			fileNumber := re.FindSubmatch([]byte(file.Name()))
-			forwardContent += fmt.Sprintf("<a class=\"main-link\" href=\"/view?id=%s\">%s (%s)</a><br/>", fileNumber[1], fileTitle, fileNumber[1])
+	var folder string
+	switch {
+	case r.URL.Path == "/":
+	case strings.HasPrefix(r.URL.Path, "/folder/"):
+		var err error
+		folder, err = cleanFolder(strings.TrimPrefix(r.URL.Path, "/folder/"))
+		if err != nil || !DirectoryExists(filepath.Join(memoDir, folder)) {
+			displayCustom404(w, r)
+			return
+		}
+	default:
+		displayCustom404(w, r)
+		return
+	}
+
+	var folders []indexLink
+	for _, sub := range subFolders(memoDir, folder) {
+		subFolder := strings.TrimPrefix(folder+"/"+sub, "/")
+		folders = append(folders, indexLink{URL: "/folder/" + folderURL(subFolder), Label: sub})
+	}
+	var memos []memoFile
+	for _, memo := range readMemos(memoDir) {
+		if memo.Folder == folder {
+			memos = append(memos, memo)
 		}
 	}
 
-	data := inputData{Title: "Home", Main: template.HTML(forwardContent), StyleSheet: template.CSS(serveStaticFile("css")), ScriptSheet: template.JS(serveStaticFile("js"))}
+	base := "/"
+	if folder != "" {
+		base = "/folder/" + folderURL(folder)
+	}
+	content, err := memoIndex(r, base, folders, memos)
 	if err != nil {
-		log.Print(err.Error())
+		log.Print(err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	err = ts.Execute(w, data)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+	title := "Home"
+	if folder != "" {
+		title = folder
 	}
+
+	renderPage(w, r, inputData{Title: title, Breadcrumbs: breadcrumbs(folder), Main: content})
 }
 
-func displayIndividualFile(w http.ResponseWriter, r *http.Request) {
-	filename := matchMemoNumber(memoNumber)
-	content, err := os.ReadFile(filename)
-	if err != nil {
+// memoURL is the address of the page of a memo, /memo/<number>/<slug>
+func memoURL(memo memoFile) string {
+	return fmt.Sprintf("/memo/%d/%s", memo.Number, url.PathEscape(slugify(memoTitle(memo.Path))))
+}
+
+// breadcrumbs links every folder leading up to folder
+func breadcrumbs(folder string) template.HTML {
+	return crumbTrail(folder, "/", func(f string) string { return "/folder/" + folderURL(f) })
+}
+
+// crumbTrail is breadcrumbs with home and the folder pages somewhere else
+func crumbTrail(folder string, home string, folderLink func(folder string) string) template.HTML {
+	crumbs := fmt.Sprintf(`<a href="%s">Home</a>`, home)
+	if folder == "" {
+		return template.HTML(crumbs)
+	}
+
+	parts := strings.Split(folder, "/")
+	for i, part := range parts {
+		crumbs += fmt.Sprintf(` / <a href="%s">%s</a>`, folderLink(strings.Join(parts[:i+1], "/")), template.HTMLEscapeString(part))
+	}
+
+	return template.HTML(crumbs)
+}
+
+func folderURL(folder string) string {
+	parts := strings.Split(folder, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// memoFromPath finds the memo of URLs like /memo/<number>/<slug>,
+// the slug is returned as is.
+func memoFromPath(urlPath string, prefix string) (memoFile, string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(urlPath, prefix), "/", 2)
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id < 1 {
+		return memoFile{}, "", false
+	}
+
+	memo, ok := memoByNumber(getKeyValue("MemoDir").(string), id)
+	if !ok {
+		return memoFile{}, "", false
+	}
+
+	slug := ""
+	if len(parts) > 1 {
+		slug = parts[1]
+	}
+
+	return memo, slug, true
+}
+
+// viewMemo shows /memo/<number> and /memo/<number>/<slug>
+func viewMemo(w http.ResponseWriter, r *http.Request) {
+	memo, slug, ok := memoFromPath(r.URL.Path, "/memo/")
+	if !ok {
 		displayCustom404(w, r)
 		return
 	}
 
-	userHTML := mdToHTML(content)
+	// Titles change, send outdated slugs to the current one
+	canonical := memoURL(memo)
+	if slug != "" && "/memo/"+strconv.Itoa(memo.Number)+"/"+url.PathEscape(slug) != canonical {
+		http.Redirect(w, r, canonical, http.StatusMovedPermanently)
+		return
+	}
 
-	ftitle := getFileTitle(filename)
+	content, err := os.ReadFile(memo.Path)
+	if err != nil {
+		displayCustom404(w, r)
+		return
+	}
 
-	homeFiles := filepath.Join(getKeyValue("StaticFiles").(string))
-	baseFile := filepath.Join(homeFiles, "base.html")
-	ts, err := template.New("base.html").ParseFiles(baseFile)
+	userHTML := memoHTML(memo, content, fmt.Sprintf("/attachments/%d/", memo.Number), func(tag string) string {
+		return "/tag/" + url.PathEscape(tag)
+	})
+	if canWrite(r) {
+		userHTML += taskForm(memo, content) + memoActions(memo)
+	} else if link := historyLink(memo); link != "" {
+		userHTML += "<div class=\"memo-actions\">" + link + "</div>"
+	}
 
-	data := inputData{
-		Title:       ftitle,
+	renderPage(w, r, inputData{
+		Title:       memoTitle(memo.Path),
+		Breadcrumbs: breadcrumbs(memo.Folder),
 		Main:        template.HTML(userHTML),
-		StyleSheet:  template.CSS(serveStaticFile("css")),
-		ScriptSheet: template.JS(serveStaticFile("js")),
+		TOC:         tocHTML(memoMarkdown(memo.Path, content)),
+	})
+}
+
+// memoHTML renders a memo followed by its tags, attachments are linked
+// under attachments and tags to what tagLink says
+func memoHTML(memo memoFile, content []byte, attachments string, tagLink func(tag string) string) string {
+	md := attachmentLinks(memoMarkdown(memo.Path, markTaskLines(content)), memo.Path, attachments)
+	userHTML := string(mdToHTML(md))
+
+	if tags := memoTags(string(content)); len(tags) > 0 {
+		var links []string
+		for _, tag := range tags {
+			links = append(links, fmt.Sprintf("<a href=\"%s\">#%s</a>", tagLink(tag), template.HTMLEscapeString(tag)))
+		}
+		userHTML += "<p class=\"memo-tags\">" + strings.Join(links, " ") + "</p>"
 	}
 
+	return userHTML
+}
+
+// rawMemo serves the memo file as it is on disk
+func rawMemo(w http.ResponseWriter, r *http.Request) {
+	memo, _, ok := memoFromPath(r.URL.Path, "/raw/")
+	if !ok {
+		displayCustom404(w, r)
+		return
+	}
+
+	content, err := os.ReadFile(memo.Path)
 	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		displayCustom404(w, r)
 		return
 	}
 
-	err = ts.Execute(w, data)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(content)
+}
+
+// tagPage lists the memos with a tag, or every tag on /tag/
+func tagPage(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.Trim(strings.TrimPrefix(r.URL.Path, "/tag/"), "/"))
+	memoDir := getKeyValue("MemoDir").(string)
+
+	counts := map[string]int{}
+	var tagged []memoFile
+	for _, memo := range readMemos(memoDir) {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		for _, t := range memoTags(string(content)) {
+			counts[t]++
+			if t == tag {
+				tagged = append(tagged, memo)
+			}
+		}
+	}
+
+	if tag == "" {
+		var tags []string
+		for t := range counts {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+
+		var content string
+		for _, t := range tags {
+			content += fmt.Sprintf("<a class=\"main-link\" href=\"/tag/%s\">#%s (%d)</a><br/>", url.PathEscape(t), template.HTMLEscapeString(t), counts[t])
+		}
+		if content == "" {
+			content = "<p>None of your memos has a tag yet.</p>"
+		}
+		renderPage(w, r, inputData{Title: "Tags", Main: template.HTML(content)})
+		return
+	}
+
+	if len(tagged) == 0 {
+		displayCustom404(w, r)
+		return
+	}
+
+	content, err := memoIndex(r, "/tag/"+url.PathEscape(tag), nil, tagged)
 	if err != nil {
-		log.Print(err.Error())
+		log.Print(err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+
+	renderPage(w, r, inputData{Title: "#" + tag, Main: content})
 }
 
-func viewFile(w http.ResponseWriter, r *http.Request) {
+// redirectView sends the old /view?id=<number> links to the memo page
+func redirectView(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if err != nil || id < 1 {
+	if err != nil {
 		displayCustom404(w, r)
 		return
 	}
 
-	viewMemo := id
-	filename := matchMemoNumber(viewMemo)
-	content, err := os.ReadFile(filename)
-	if err != nil {
+	memo, ok := memoByNumber(getKeyValue("MemoDir").(string), id)
+	if !ok {
 		displayCustom404(w, r)
 		return
 	}
-	userHTML := mdToHTML(content)
-	homeFiles := filepath.Join(getKeyValue("StaticFiles").(string))
 
-	baseFile := filepath.Join(homeFiles, "base.html")
-	ts, err := template.New("base.html").ParseFiles(baseFile)
+	http.Redirect(w, r, memoURL(memo), http.StatusMovedPermanently)
+}
 
-	data := inputData{Title: getFileTitle(matchMemoNumber(id)), Main: template.HTML(userHTML), StyleSheet: template.CSS(serveStaticFile("css")), ScriptSheet: template.JS(serveStaticFile("js"))}
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+// serveAttachment serves /attachments/<memo number>/<name>
+func serveAttachment(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/attachments/"), "/", 2)
+	if len(parts) != 2 {
+		displayCustom404(w, r)
 		return
 	}
-	err = ts.Execute(w, data)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+	id, err := strconv.Atoi(parts[0])
+	name := path.Base(path.Clean("/" + parts[1]))
+	if err != nil || name == "/" || name == "." {
+		displayCustom404(w, r)
+		return
 	}
+
+	filename := matchMemoNumber(id)
+	if filename == "" {
+		displayCustom404(w, r)
+		return
+	}
+
+	attachment := filepath.Join(assetsDir(filename), name)
+	if !FileExists(attachment) {
+		displayCustom404(w, r)
+		return
+	}
+
+	// ServeFile picks the Content-Type from the extension
+	http.ServeFile(w, r, attachment)
 }
 
 func displayCustom404(w http.ResponseWriter, r *http.Request) {
@@ -207,48 +490,52 @@ func displayCustom404(w http.ResponseWriter, r *http.Request) {
 		</div>
 	</div>
   `
-	homeFiles := filepath.Join(getKeyValue("StaticFiles").(string))
 
-	baseFile := filepath.Join(homeFiles, "base.html")
-	ts, err := template.New("base.html").ParseFiles(baseFile)
-
-	data := inputData{Title: "404 Page Not Found", Main: template.HTML(htmlCode), StyleSheet: template.CSS(serveStaticFile("css"))}
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-	err = ts.Execute(w, data)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-	}
+	renderPage(w, r, inputData{Title: "404 Page Not Found", Main: template.HTML(htmlCode)})
 }
 
-func displayIndex() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", home)
-	mux.HandleFunc("/view", viewFile)
-	log.Print("Serve opened at http://127.0.0.0:4000/")
-	err := http.ListenAndServe(":4000", mux)
-	log.Fatal(err)
+// markdownParser reads memos, a parser is only good for one of them
+func markdownParser() *parser.Parser {
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock | parser.MathJax
+	return parser.NewWithExtensions(extensions)
 }
 
+// mdToHTML renders markdown for the browser, cleaned up according to
+// the sanitize setting since memos can come from anywhere.
 func mdToHTML(md []byte) []byte {
+	mode, _ := sanitizeMode()
 
-	// No checks or sanitization provided yet!
-	// It is expected to run on users system so most of the attacks
-	// are not an imminent threat, for now
-
-	// create markdown parser with extensions
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
-	p := parser.NewWithExtensions(extensions)
-	doc := p.Parse(md)
+	doc := markdownParser().Parse(md)
+	markTasks(doc)
 
 	// create HTML renderer with extensions
 	htmlFlags := html.CommonFlags | html.HrefTargetBlank
-	opts := html.RendererOptions{Flags: htmlFlags}
+	if mode == sanitizeStrict {
+		htmlFlags |= html.SkipHTML
+	}
+	hooks := []html.RenderNodeFunc{renderHeadingAnchor, renderTaskBox, renderMath, renderDiagram}
+	if style, _ := highlightStyle(); style != nil {
+		hooks = append(hooks, highlightCode)
+	}
+	opts := html.RendererOptions{Flags: htmlFlags, RenderNodeHook: chainHooks(hooks...)}
 	renderer := html.NewRenderer(opts)
 
-	return markdown.Render(doc, renderer)
+	rendered := markdown.Render(doc, renderer)
+	if mode == sanitizeTrusted {
+		return rendered
+	}
+
+	return memoPolicy().SanitizeBytes(rendered)
+}
+
+// chainHooks gives a node to each render hook until one renders it
+func chainHooks(hooks ...html.RenderNodeFunc) html.RenderNodeFunc {
+	return func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		for _, hook := range hooks {
+			if status, done := hook(w, node, entering); done {
+				return status, true
+			}
+		}
+		return ast.GoToNext, false
+	}
 }
@@ -0,0 +1,181 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Used when neither the flags nor ServeAddr say otherwise
+const defaultServeAddr = "127.0.0.1:4000"
+
+// How long the open requests get to finish on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// listenAddress works out where to listen, --addr and --port win over
+// ServeAddr in the config which wins over the default.
+func listenAddress(cmd *cobra.Command) (string, error) {
+	addr := getKeyValue("ServeAddr").(string)
+	if addr == "" {
+		addr = defaultServeAddr
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid serveaddr %q: %v", addr, err)
+	}
+
+	if cmd.Flag("addr").Changed {
+		host, _ = cmd.Flags().GetString("addr")
+	}
+	if cmd.Flag("port").Changed {
+		p, _ := cmd.Flags().GetInt("port")
+		if p < 0 || p > 65535 {
+			return "", fmt.Errorf("%d is not a valid port", p)
+		}
+		port = strconv.Itoa(p)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// startServer serves handler on addr until SIGINT or SIGTERM, then
+// waits for the open requests before returning. openPath is opened in
+// the browser when open is set. HTTPS is used when tlsConfig is set.
+// onShutdown ends the requests that never end on their own, like the
+// live reload stream.
+func startServer(handler http.Handler, addr string, openPath string, open bool, tlsConfig *tls.Config, onShutdown func()) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Couldn't listen on %s: %v", addr, err)
+	}
+
+	scheme := "http://"
+	tcpAddr := ln.Addr().(*net.TCPAddr)
+	if tlsConfig != nil {
+		scheme = "https://"
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	url := scheme + browsableAddr(tcpAddr) + openPath
+	srv := &http.Server{
+		Handler:           logRequests(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if onShutdown != nil {
+		srv.RegisterOnShutdown(onShutdown)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+	log.Printf("Serving your memos at %s", url)
+
+	if open {
+		if err := openBrowser(url); err != nil {
+			log.Printf("Couldn't open the browser: %v", err)
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	case sig := <-stop:
+		log.Printf("Got %v, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("Couldn't shut down cleanly: %v", err)
+		}
+	}
+}
+
+// warnInsecure points out when memos can be reached by others without
+// a password, or when passwords go over the network in clear text
+func warnInsecure(addr string, auth bool, https bool) {
+	host, _, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return
+	}
+
+	switch {
+	case !auth:
+		log.Print("Anybody who can reach this machine can read and change your memos, add users with `memo user add`")
+	case !https:
+		log.Print("Passwords are sent in clear text, use --tls")
+	}
+}
+
+// browsableAddr is the address to put in a URL, a server listening on
+// every interface can be reached on the loopback one.
+func browsableAddr(addr *net.TCPAddr) string {
+	host := addr.IP.String()
+	if addr.IP == nil || addr.IP.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(addr.Port))
+}
+
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	return cmd.Start()
+}
+
+// statusRecorder remembers the status code for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets the live reload stream through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %d %v", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+	})
+}
@@ -0,0 +1,349 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+)
+
+// How many months, weeks and days of commits the charts show
+const (
+	statsMonths = 12
+	statsWeeks  = 12
+	statsDays   = 30
+)
+
+type statCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+type statMemo struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Words int    `json:"words"`
+}
+
+type statStreak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// memoStats is what memo stats reports, Months, Weeks and Commits have
+// every period from the first to the last with something in it
+type memoStats struct {
+	Memos         int         `json:"memos"`
+	Words         int         `json:"words"`
+	Characters    int         `json:"characters"`
+	Months        []statCount `json:"months"`
+	Weeks         []statCount `json:"weeks"`
+	CurrentStreak statStreak  `json:"current_streak"`
+	LongestStreak statStreak  `json:"longest_streak"`
+	Tags          []statCount `json:"tags"`
+	Largest       []statMemo  `json:"largest"`
+	TotalCommits  int         `json:"total_commits"`
+	Commits       []statCount `json:"commits"`
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics about your memos",
+	Long: `Count the memos, words and characters, the memos written each month and
+week, the writing streaks, the most used tags, the largest memos and the
+commits made each day`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		top, _ := cmd.Flags().GetInt("top")
+		if format != "text" && format != "json" {
+			log.Fatalf("Unknown format %q, use text or json", format)
+		}
+		if top < 0 {
+			log.Fatalf("--top can't be negative, got %d", top)
+		}
+
+		stats := collectStats(getKeyValue("MemoDir").(string), top, time.Now())
+		if format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(stats); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		printStats(stats)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().String("format", "text", "Output format, text or json")
+	statsCmd.Flags().Int("top", 5, "How many tags and memos to list")
+}
+
+func collectStats(memoDir string, top int, now time.Time) memoStats {
+	stats := memoStats{Tags: []statCount{}, Largest: []statMemo{}}
+	days := map[string]int{}
+	tags := map[string]int{}
+
+	for _, memo := range readMemos(memoDir) {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		fm, body := splitFrontMatter(string(content))
+
+		words := len(strings.Fields(body))
+		stats.Memos++
+		stats.Words += words
+		stats.Characters += utf8.RuneCountInString(body)
+		stats.Largest = append(stats.Largest, statMemo{
+			ID:    memo.Number,
+			Title: strings.TrimSpace(getFileTitle(memo.Path)),
+			Words: words,
+		})
+
+		if date := memoDate(memo, fm); !date.IsZero() {
+			days[date.Format(time.DateOnly)]++
+		}
+		for _, tag := range memoTags(string(content)) {
+			tags[tag]++
+		}
+	}
+
+	sort.SliceStable(stats.Largest, func(i, j int) bool { return stats.Largest[i].Words > stats.Largest[j].Words })
+	if len(stats.Largest) > top {
+		stats.Largest = stats.Largest[:top]
+	}
+
+	for tag, count := range tags {
+		stats.Tags = append(stats.Tags, statCount{Label: tag, Count: count})
+	}
+	sort.Slice(stats.Tags, func(i, j int) bool {
+		if stats.Tags[i].Count != stats.Tags[j].Count {
+			return stats.Tags[i].Count > stats.Tags[j].Count
+		}
+		return stats.Tags[i].Label < stats.Tags[j].Label
+	})
+	if len(stats.Tags) > top {
+		stats.Tags = stats.Tags[:top]
+	}
+
+	stats.Months = periodCounts(days, func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+	}, func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, func(t time.Time) string { return t.Format(monthLayout) })
+	stats.Weeks = periodCounts(days, func(day time.Time) time.Time {
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}, func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	commits := gitActivity(memoDir)
+	for _, count := range commits {
+		stats.TotalCommits += count
+	}
+	stats.Commits = periodCounts(commits, func(day time.Time) time.Time { return day },
+		func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, func(t time.Time) string { return t.Format(time.DateOnly) })
+
+	// Editing is writing too, a day with a commit keeps the streak going
+	written := map[string]int{}
+	for day, count := range days {
+		written[day] += count
+	}
+	for day, count := range commits {
+		written[day] += count
+	}
+	stats.CurrentStreak, stats.LongestStreak = writingStreaks(written, now)
+
+	return stats
+}
+
+// gitActivity counts the commits of the notebook by the day they were
+// made, nothing when it isn't a git repository
+func gitActivity(memoDir string) map[string]int {
+	days := map[string]int{}
+
+	repo, err := git.PlainOpen(memoDir)
+	if err != nil {
+		return days
+	}
+	commits, err := repo.Log(&git.LogOptions{})
+	if err != nil {
+		// No commits yet
+		return days
+	}
+	commits.ForEach(func(c *object.Commit) error {
+		days[c.Author.When.Local().Format(time.DateOnly)]++
+		return nil
+	})
+
+	return days
+}
+
+// periodCounts adds up the counts of days (2006-01-02) by the period
+// start puts them in, from the first period to the last
+func periodCounts(days map[string]int, start func(time.Time) time.Time, next func(time.Time) time.Time, label func(time.Time) string) []statCount {
+	var first, last time.Time
+	periods := map[string]int{}
+	for day, count := range days {
+		date, err := time.ParseInLocation(time.DateOnly, day, time.Local)
+		if err != nil {
+			continue
+		}
+		period := start(date)
+		periods[label(period)] += count
+		if first.IsZero() || period.Before(first) {
+			first = period
+		}
+		if last.IsZero() || period.After(last) {
+			last = period
+		}
+	}
+
+	counts := []statCount{}
+	if first.IsZero() {
+		return counts
+	}
+	for t := first; !t.After(last); t = next(t) {
+		counts = append(counts, statCount{Label: label(t), Count: periods[label(t)]})
+	}
+
+	return counts
+}
+
+// writingStreaks finds the days in a row with something written, the
+// current streak ends today or yesterday
+func writingStreaks(days map[string]int, now time.Time) (current statStreak, longest statStreak) {
+	var dates []time.Time
+	for day := range days {
+		if date, err := time.ParseInLocation(time.DateOnly, day, time.Local); err == nil {
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	var streak statStreak
+	for i, date := range dates {
+		if i > 0 && dates[i-1].AddDate(0, 0, 1).Equal(date) {
+			streak.Days++
+		} else {
+			streak = statStreak{Days: 1, Start: date.Format(time.DateOnly)}
+		}
+		streak.End = date.Format(time.DateOnly)
+		if streak.Days > longest.Days {
+			longest = streak
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if streak.End == today.Format(time.DateOnly) || streak.End == today.AddDate(0, 0, -1).Format(time.DateOnly) {
+		current = streak
+	}
+
+	return current, longest
+}
+
+func printStats(stats memoStats) {
+	headStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("99"))
+	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	fmt.Println(headStyle.Render("Memos"))
+	fmt.Printf("%s %d\n%s %d\n%s %d\n", labelStyle.Render("Memos:     "), stats.Memos,
+		labelStyle.Render("Words:     "), stats.Words, labelStyle.Render("Characters:"), stats.Characters)
+	fmt.Printf("%s %s\n%s %s\n\n", labelStyle.Render("Streak:    "), streakText(stats.CurrentStreak),
+		labelStyle.Render("Longest:   "), streakText(stats.LongestStreak))
+
+	printBars(headStyle.Render("Memos per month"), lastCounts(stats.Months, statsMonths))
+	printBars(headStyle.Render("Memos per week"), lastCounts(stats.Weeks, statsWeeks))
+
+	var tags []statCount
+	for _, tag := range stats.Tags {
+		tags = append(tags, statCount{Label: "#" + tag.Label, Count: tag.Count})
+	}
+	printBars(headStyle.Render("Most used tags"), tags)
+
+	var largest []statCount
+	for _, memo := range stats.Largest {
+		largest = append(largest, statCount{Label: fmt.Sprintf("%d %s", memo.ID, memo.Title), Count: memo.Words})
+	}
+	printBars(headStyle.Render("Largest memos (words)"), largest)
+
+	printBars(headStyle.Render(fmt.Sprintf("Commits per day (%d in all)", stats.TotalCommits)), lastCounts(stats.Commits, statsDays))
+}
+
+func streakText(streak statStreak) string {
+	switch streak.Days {
+	case 0:
+		return "none"
+	case 1:
+		return "1 day (" + streak.Start + ")"
+	}
+
+	return fmt.Sprintf("%d days (%s to %s)", streak.Days, streak.Start, streak.End)
+}
+
+// lastCounts are the last n of counts
+func lastCounts(counts []statCount, n int) []statCount {
+	if len(counts) > n {
+		return counts[len(counts)-n:]
+	}
+
+	return counts
+}
+
+// printBars draws counts as a bar chart as wide as the terminal
+func printBars(title string, counts []statCount) {
+	fmt.Println(title)
+	if len(counts) == 0 {
+		fmt.Println("  nothing yet")
+		fmt.Println()
+		return
+	}
+
+	barStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+	labelWidth, most := 0, 0
+	for _, count := range counts {
+		labelWidth = max(labelWidth, runewidth.StringWidth(count.Label))
+		most = max(most, count.Count)
+	}
+	labelWidth = min(labelWidth, 30)
+
+	width := CalcTermSize() - labelWidth - 12
+	if width < 10 {
+		width = 10
+	}
+
+	for _, count := range counts {
+		// Wide characters take two cells, cut by what the label takes
+		// on screen
+		label := runewidth.Truncate(count.Label, labelWidth, "…")
+		bar := 0
+		if most > 0 {
+			bar = count.Count * width / most
+		}
+		if bar == 0 && count.Count > 0 {
+			bar = 1
+		}
+		label += strings.Repeat(" ", max(labelWidth-runewidth.StringWidth(label), 0))
+		fmt.Printf("  %s %s %d\n", label, barStyle.Render(strings.Repeat("█", bar)), count.Count)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestPeriodCounts(t *testing.T) {
+	month := func(day time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	nextMonth := func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	monthLabel := func(t time.Time) string { return t.Format("2006-01") }
+
+	tests := []struct {
+		name string
+		days map[string]int
+		want []statCount
+	}{
+		{"nothing", map[string]int{}, []statCount{}},
+		{"one day", map[string]int{"2024-03-05": 2}, []statCount{{"2024-03", 2}}},
+		{"added up", map[string]int{"2024-03-05": 2, "2024-03-31": 1}, []statCount{{"2024-03", 3}}},
+		// Months without memos are there with 0
+		{"gaps", map[string]int{"2024-11-30": 1, "2025-02-01": 4}, []statCount{
+			{"2024-11", 1}, {"2024-12", 0}, {"2025-01", 0}, {"2025-02", 4},
+		}},
+		{"bad dates skipped", map[string]int{"2024-03-05": 1, "someday": 9}, []statCount{{"2024-03", 1}}},
+	}
+
+	for _, test := range tests {
+		got := periodCounts(test.days, month, nextMonth, monthLabel)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: periodCounts = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWritingStreaks(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.Local)
+	days := func(dates ...string) map[string]int {
+		m := map[string]int{}
+		for _, date := range dates {
+			m[date] = 1
+		}
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		days    map[string]int
+		current statStreak
+		longest statStreak
+	}{
+		{"nothing", days(), statStreak{}, statStreak{}},
+		{"today", days("2024-03-10"), statStreak{1, "2024-03-10", "2024-03-10"}, statStreak{1, "2024-03-10", "2024-03-10"}},
+		// Today isn't over, a streak up to yesterday still goes on
+		{"up to yesterday", days("2024-03-08", "2024-03-09"),
+			statStreak{2, "2024-03-08", "2024-03-09"}, statStreak{2, "2024-03-08", "2024-03-09"}},
+		{"broken", days("2024-03-07", "2024-03-08"), statStreak{}, statStreak{2, "2024-03-07", "2024-03-08"}},
+		{"longest earlier", days("2024-01-01", "2024-01-02", "2024-01-03", "2024-03-09", "2024-03-10"),
+			statStreak{2, "2024-03-09", "2024-03-10"}, statStreak{3, "2024-01-01", "2024-01-03"}},
+		{"over a month", days("2024-02-28", "2024-02-29", "2024-03-01"),
+			statStreak{}, statStreak{3, "2024-02-28", "2024-03-01"}},
+		{"first longest kept", days("2024-01-01", "2024-01-02", "2024-02-01", "2024-02-02"),
+			statStreak{}, statStreak{2, "2024-01-01", "2024-01-02"}},
+	}
+
+	for _, test := range tests {
+		current, longest := writingStreaks(test.days, now)
+		if current != test.current || longest != test.longest {
+			t.Errorf("%s: writingStreaks = %v, %v, want %v, %v", test.name, current, longest, test.current, test.longest)
+		}
+	}
+}
+
+// captureStdout returns what print writes to the standard output
+func captureStdout(t *testing.T, print func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	print()
+	w.Close()
+
+	return <-done
+}
+
+var ansiCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func TestPrintBars(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []statCount
+	}{
+		{"plain", []statCount{{"2024-01", 3}, {"2024-02", 0}, {"2024-03", 12}}},
+		{"long", []statCount{{strings.Repeat("x", 50), 1}, {"short", 2}}},
+		// Labels wider on screen than they are long made the padding
+		// negative
+		{"wide", []statCount{{"12 " + strings.Repeat("日本語", 12), 5}, {"3 a", 1}}},
+		{"emoji", []statCount{{"#🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉🎉", 2}}},
+		{"zero", []statCount{{"none", 0}}},
+	}
+
+	for _, test := range tests {
+		out := captureStdout(t, func() { printBars("Title", test.counts) })
+
+		lines := strings.Split(strings.TrimSuffix(out, "\n\n"), "\n")
+		if len(lines) != len(test.counts)+1 {
+			t.Errorf("%s: printed %d lines, want %d:\n%s", test.name, len(lines), len(test.counts)+1, out)
+			continue
+		}
+
+		// The bars start in the same column
+		column := -1
+		for i, line := range lines[1:] {
+			// "  <label> <bar> <count>", the bar can be empty
+			line = ansiCodes.ReplaceAllString(strings.TrimPrefix(line, "  "), "")
+			label := strings.TrimSuffix(strings.TrimRight(line[:strings.LastIndex(line, " ")], "█"), " ")
+			if width := runewidth.StringWidth(label); width > 30 {
+				t.Errorf("%s: label %d takes %d cells", test.name, i, width)
+			}
+			if column >= 0 && runewidth.StringWidth(label) != column {
+				t.Errorf("%s: the bars don't line up:\n%s", test.name, out)
+			}
+			column = runewidth.StringWidth(label)
+		}
+	}
+
+	if out := captureStdout(t, func() { printBars("Title", nil) }); out != "Title\n  nothing yet\n\n" {
+		t.Errorf("printBars with nothing = %q", out)
+	}
+}
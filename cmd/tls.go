@@ -0,0 +1,166 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// The generated certificate is kept next to the config so browsers
+// only have to accept it once
+const (
+	selfSignedCert = "serve-cert.pem"
+	selfSignedKey  = "serve-key.pem"
+	// Replaced this long before it expires
+	selfSignedRenew = 30 * 24 * time.Hour
+)
+
+// serverTLSConfig works out whether to serve over HTTPS. The flags win
+// over tlscert/tlskey in the config, --tls without a certificate uses a
+// self-signed one. nil means plain HTTP.
+func serverTLSConfig(cmd *cobra.Command, addr string) (*tls.Config, error) {
+	certFile := getKeyValue("TLSCert").(string)
+	keyFile := getKeyValue("TLSKey").(string)
+	if cmd.Flag("tls-cert").Changed {
+		certFile, _ = cmd.Flags().GetString("tls-cert")
+	}
+	if cmd.Flag("tls-key").Changed {
+		keyFile, _ = cmd.Flags().GetString("tls-key")
+	}
+	useTLS, _ := cmd.Flags().GetBool("tls")
+
+	if certFile == "" && keyFile == "" {
+		if !useTLS {
+			return nil, nil
+		}
+		dir := getKeyValue("configDir").(string)
+		certFile = filepath.Join(dir, selfSignedCert)
+		keyFile = filepath.Join(dir, selfSignedKey)
+		if err := ensureSelfSigned(certFile, keyFile, addr); err != nil {
+			return nil, fmt.Errorf("couldn't create a self-signed certificate: %v", err)
+		}
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("TLS needs both a certificate and its key")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		log.Printf("Certificate fingerprint (SHA-256): %X", sha256.Sum256(leaf.Raw))
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ensureSelfSigned creates the certificate unless a usable one exists
+func ensureSelfSigned(certFile string, keyFile string, addr string) error {
+	if pemBytes, err := os.ReadFile(certFile); err == nil && FileExists(keyFile) {
+		if block, _ := pem.Decode(pemBytes); block != nil {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil && time.Until(cert.NotAfter) > selfSignedRenew {
+				return nil
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"memo"}, CommonName: "memo serve"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range certificateHosts(addr) {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+
+	log.Printf("Created a self-signed certificate in %s, delete it to make a new one", certFile)
+	return nil
+}
+
+// certificateHosts are the names the server can be reached by, the
+// addresses of every interface when it listens on all of them
+func certificateHosts(addr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name)
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if addrs, err := net.InterfaceAddrs(); err == nil {
+			for _, a := range addrs {
+				if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+					hosts = append(hosts, ipNet.IP.String())
+				}
+			}
+		}
+	} else {
+		hosts = append(hosts, host)
+	}
+
+	seen := map[string]bool{}
+	var unique []string
+	for _, h := range hosts {
+		if !seen[h] {
+			seen[h] = true
+			unique = append(unique, h)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,220 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// memoHeading is a heading of a memo, ID is its anchor on the web page
+// and Line where it starts in the markdown (-1 when unknown). Title is
+// set for a first level heading opening the memo.
+type memoHeading struct {
+	Level int
+	Text  string
+	ID    string
+	Line  int
+	Title bool
+}
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}#{1,6}(\s|$)`)
+	setextUnder   = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	notSetextText = regexp.MustCompile(`^(\s{4}|\s*([-*+>]|\d+[.)])\s|\s*$)`)
+)
+
+// memoHeadings lists the headings of a memo which aren't nested in
+// lists or quotes, the IDs are the ones mdToHTML gives them
+func memoHeadings(md []byte) []memoHeading {
+	var headings []memoHeading
+	for i, node := range markdownParser().Parse(md).GetChildren() {
+		heading, ok := node.(*ast.Heading)
+		if !ok || heading.IsTitleblock {
+			continue
+		}
+		headings = append(headings, memoHeading{
+			Level: heading.Level,
+			Text:  strings.TrimSpace(nodeText(heading)),
+			ID:    heading.HeadingID,
+			Line:  -1,
+			Title: i == 0 && heading.Level == 1,
+		})
+	}
+
+	// The parser doesn't say where nodes start, the lines are found
+	// again and trusted when there are as many
+	lines := headingLines(string(md))
+	if len(lines) == len(headings) {
+		for i := range headings {
+			headings[i].Line = lines[i]
+		}
+	}
+
+	return headings
+}
+
+// headingLines are the lines starting a heading, outside of code blocks
+func headingLines(md string) []int {
+	var found []int
+	lines := strings.Split(md, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		if atxHeading.MatchString(line) {
+			found = append(found, i)
+			continue
+		}
+		// Text underlined with === or ---, after a blank line
+		if i+1 < len(lines) && setextUnder.MatchString(lines[i+1]) && !notSetextText.MatchString(line) &&
+			(i == 0 || strings.TrimSpace(lines[i-1]) == "") {
+			found = append(found, i)
+		}
+	}
+
+	return found
+}
+
+// nodeText is the text of node without its markup
+func nodeText(node ast.Node) string {
+	var text strings.Builder
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Text:
+			text.Write(n.Literal)
+		case *ast.Code:
+			text.Write(n.Literal)
+		case *ast.Math:
+			text.Write(n.Literal)
+		}
+		return ast.GoToNext
+	})
+
+	return text.String()
+}
+
+// tocHeadings are the headings put in a table of contents, a heading
+// opening the memo is its title and left out
+func tocHeadings(md []byte) []memoHeading {
+	headings := memoHeadings(md)
+	if len(headings) > 0 && headings[0].Title {
+		headings = headings[1:]
+	}
+
+	return headings
+}
+
+// topLevel is the level of the biggest headings
+func topLevel(headings []memoHeading) int {
+	top := headings[0].Level
+	for _, heading := range headings {
+		top = min(top, heading.Level)
+	}
+
+	return top
+}
+
+// tocHTML is the table of contents shown next to a memo, there is none
+// for less than two headings
+func tocHTML(md []byte) template.HTML {
+	headings := tocHeadings(md)
+	if len(headings) < 2 {
+		return ""
+	}
+
+	top := topLevel(headings)
+	var toc strings.Builder
+	toc.WriteString("<nav class=\"toc\"><strong>Contents</strong><ul>")
+	for _, heading := range headings {
+		fmt.Fprintf(&toc, "<li class=\"toc-%d\"><a href=\"#%s\">%s</a></li>", heading.Level-top+1,
+			template.HTMLEscapeString(heading.ID), template.HTMLEscapeString(heading.Text))
+	}
+	toc.WriteString("</ul></nav>")
+
+	return template.HTML(toc.String())
+}
+
+// tocMarkdown is the table of contents as a markdown list for the
+// terminal
+func tocMarkdown(md []byte) string {
+	headings := tocHeadings(md)
+	if len(headings) == 0 {
+		return ""
+	}
+
+	top := topLevel(headings)
+	var toc strings.Builder
+	toc.WriteString("**Contents**\n\n")
+	for _, heading := range headings {
+		fmt.Fprintf(&toc, "%s- %s `#%s`\n", strings.Repeat("  ", heading.Level-top), heading.Text, heading.ID)
+	}
+
+	return toc.String() + "\n---\n\n"
+}
+
+// memoSection is the part of a memo under the heading named section,
+// up to the next heading as big as that one. The section is found by
+// its anchor or its text.
+func memoSection(md []byte, section string) (string, bool) {
+	headings := memoHeadings(md)
+	wanted := strings.ToLower(strings.TrimSpace(section))
+
+	found := -1
+	for i, heading := range headings {
+		if heading.ID == section {
+			found = i
+			break
+		}
+		if found < 0 && (strings.ToLower(heading.ID) == wanted || strings.ToLower(heading.Text) == wanted ||
+			strings.ToLower(heading.ID) == strings.Join(strings.Fields(wanted), "-")) {
+			found = i
+		}
+	}
+	if found < 0 || headings[found].Line < 0 {
+		return "", false
+	}
+
+	lines := strings.Split(string(md), "\n")
+	end := len(lines)
+	for _, heading := range headings[found+1:] {
+		if heading.Level <= headings[found].Level {
+			end = heading.Line
+			break
+		}
+	}
+
+	return strings.Join(lines[headings[found].Line:end], "\n"), true
+}
+
+// renderHeadingAnchor is a render hook ending headings with a link to
+// them
+func renderHeadingAnchor(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	heading, ok := node.(*ast.Heading)
+	if !ok || entering || heading.HeadingID == "" || heading.IsTitleblock {
+		return ast.GoToNext, false
+	}
+
+	fmt.Fprintf(w, "<a class=\"heading-anchor\" href=\"#%s\" title=\"Link to this section\">#</a></h%d>\n",
+		template.HTMLEscapeString(heading.HeadingID), heading.Level)
+	return ast.GoToNext, true
+}
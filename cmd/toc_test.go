@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const tocMemo = "# Notes\n\nIntro\n\n## Install\n\nText\n\n```sh\n# not a heading\n```\n\n### On Linux\n\n## 日本語の見出し\n\n## Привет мир\n\n## Café crème\n\n## Install\n\nSetext\n------\n"
+
+func TestMemoHeadings(t *testing.T) {
+	want := []memoHeading{
+		{Level: 1, Text: "Notes", ID: "notes", Line: 0, Title: true},
+		{Level: 2, Text: "Install", ID: "install", Line: 4},
+		{Level: 3, Text: "On Linux", ID: "on-linux", Line: 12},
+		{Level: 2, Text: "日本語の見出し", ID: "日本語の見出し", Line: 14},
+		{Level: 2, Text: "Привет мир", ID: "привет-мир", Line: 16},
+		{Level: 2, Text: "Café crème", ID: "café-crème", Line: 18},
+		{Level: 2, Text: "Install", ID: "install-1", Line: 20},
+		{Level: 2, Text: "Setext", ID: "setext", Line: 22},
+	}
+
+	got := memoHeadings([]byte(tocMemo))
+	if len(got) != len(want) {
+		t.Fatalf("memoHeadings found %d headings, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("heading %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+var anchorIDs = regexp.MustCompile(`<h\d id="([^"]+)"`)
+
+// Every link of the table of contents goes to a heading of the page,
+// whatever the script of the heading
+func TestTOCLinksMatchIDs(t *testing.T) {
+	for _, sanitize := range []string{"", "strict", "trusted"} {
+		useConfig(t, "sanitize = \""+sanitize+"\"\n")
+
+		ids := map[string]bool{}
+		for _, m := range anchorIDs.FindAllStringSubmatch(string(mdToHTML([]byte(tocMemo))), -1) {
+			ids[m[1]] = true
+		}
+
+		toc := string(tocHTML([]byte(tocMemo)))
+		links := regexp.MustCompile(`href="#([^"]+)"`).FindAllStringSubmatch(toc, -1)
+		if len(links) != 7 {
+			t.Errorf("sanitize %q: the table of contents has %d links, want 7:\n%s", sanitize, len(links), toc)
+		}
+		for _, link := range links {
+			if !ids[link[1]] {
+				t.Errorf("sanitize %q: no heading has the id %q of the table of contents, the page has %v", sanitize, link[1], ids)
+			}
+		}
+	}
+}
+
+func TestMemoSection(t *testing.T) {
+	tests := []struct {
+		section string
+		want    string
+		ok      bool
+	}{
+		{"install", "## Install\n\nText\n\n```sh\n# not a heading\n```\n\n### On Linux\n", true},
+		{"On Linux", "### On Linux\n", true},
+		{"on linux", "### On Linux\n", true},
+		{"日本語の見出し", "## 日本語の見出し\n", true},
+		{"install-1", "## Install\n", true},
+		{"setext", "Setext\n------\n", true},
+		{"nowhere", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := memoSection([]byte(tocMemo), test.section)
+		if ok != test.ok || got != test.want {
+			t.Errorf("memoSection(%q) = %q, %v, want %q, %v", test.section, got, ok, test.want, test.ok)
+		}
+	}
+
+	if strings.Contains(string(tocMarkdown([]byte(tocMemo))), "Notes") {
+		t.Error("the title of the memo is in its table of contents")
+	}
+}
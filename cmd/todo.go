@@ -0,0 +1,470 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/spf13/cobra"
+)
+
+// memoTask is a `- [ ] item` of a memo, Line counts from 1 in the file.
+// Priority goes from 1 (high) to 3 (low), 0 when not given.
+type memoTask struct {
+	Memo     memoFile
+	Line     int
+	Done     bool
+	Text     string
+	Due      time.Time
+	Priority int
+}
+
+var (
+	taskLine     = regexp.MustCompile(`^(\s*(?:>\s*)*(?:[-*+]|\d+[.)])\s+\[)([ xX])(\]\s+)(.*)$`)
+	taskDue      = regexp.MustCompile(`\s*@due\((\d{4}-\d{2}-\d{2})\)`)
+	taskPriority = regexp.MustCompile(`(?i)\s*@priority\((high|medium|low|[1-3])\)`)
+)
+
+var taskPriorities = map[string]int{"high": 1, "medium": 2, "low": 3, "1": 1, "2": 2, "3": 3}
+var priorityNames = []string{"", "high", "medium", "low"}
+
+var todoCmd = &cobra.Command{
+	Use:   "todo",
+	Short: "List the open tasks of your memos",
+	Long: `List the - [ ] tasks written in memos, the ones due first on top.
+
+A task can have a due date and a priority:
+
+  - [ ] Renew the passport @due(2026-11-01) @priority(high)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		done, _ := cmd.Flags().GetBool("done")
+		folder, _ := cmd.Flags().GetString("folder")
+		folder, err := cleanFolder(folder)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var tasks []memoTask
+		for _, task := range allTasks(getKeyValue("MemoDir").(string)) {
+			if (done || !task.Done) && inFolder(task.Memo, folder) {
+				tasks = append(tasks, task)
+			}
+		}
+		if len(tasks) == 0 {
+			fmt.Println("Nothing to do :)")
+			return
+		}
+
+		printTasks(tasks)
+	},
+}
+
+var todoDoneCmd = &cobra.Command{
+	Use:   "done <memo>:<line>",
+	Short: "Tick a task off, or open it again",
+	Long:  `Tick a task off, or open it again when it was done. memo todo shows where the tasks are.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		memo, line, err := parseTaskRef(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		task, err := toggleTask(memo, line)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		state := "Done"
+		if !task.Done {
+			state = "Open again"
+		}
+		fmt.Printf("%s: %s\n", state, task.Text)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(todoCmd)
+	todoCmd.AddCommand(todoDoneCmd)
+	todoCmd.Flags().BoolP("done", "d", false, "List the finished tasks too")
+	todoCmd.Flags().StringP("folder", "f", "", "Only list the tasks in this folder")
+}
+
+// parseTask reads a task out of a line, ok is false for other lines
+func parseTask(line string) (memoTask, bool) {
+	m := taskLine.FindStringSubmatch(line)
+	if m == nil {
+		return memoTask{}, false
+	}
+
+	task := memoTask{Done: m[2] != " ", Text: m[4]}
+	if due := taskDue.FindStringSubmatch(task.Text); due != nil {
+		if date, err := time.ParseInLocation(time.DateOnly, due[1], time.Local); err == nil {
+			task.Due = date
+		}
+	}
+	if priority := taskPriority.FindStringSubmatch(task.Text); priority != nil {
+		task.Priority = taskPriorities[strings.ToLower(priority[1])]
+	}
+	task.Text = strings.TrimSpace(taskPriority.ReplaceAllString(taskDue.ReplaceAllString(task.Text, ""), ""))
+
+	return task, true
+}
+
+// readTasks lists the tasks of a memo in order, the front matter and
+// code blocks are skipped
+func readTasks(memo memoFile, content []byte) []memoTask {
+	var tasks []memoTask
+	_, body := splitFrontMatter(string(content))
+	skip := strings.Count(string(content), "\n") - strings.Count(body, "\n")
+	fence := ""
+	for i, line := range strings.Split(string(content), "\n") {
+		if i < skip {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		// Only a fence at least as long closes the block
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+			continue
+		}
+
+		if task, ok := parseTask(line); ok {
+			task.Memo = memo
+			task.Line = i + 1
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks
+}
+
+// allTasks are the tasks of every memo, the ones due first, then by
+// priority and where they are written
+func allTasks(memoDir string) []memoTask {
+	var tasks []memoTask
+	for _, memo := range readMemos(memoDir) {
+		content, err := os.ReadFile(memo.Path)
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, readTasks(memo, content)...)
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if !a.Due.Equal(b.Due) {
+			if a.Due.IsZero() || b.Due.IsZero() {
+				return b.Due.IsZero()
+			}
+			return a.Due.Before(b.Due)
+		}
+		if a.Priority != b.Priority {
+			if a.Priority == 0 || b.Priority == 0 {
+				return b.Priority == 0
+			}
+			return a.Priority < b.Priority
+		}
+		if a.Memo.Number != b.Memo.Number {
+			return a.Memo.Number < b.Memo.Number
+		}
+		return a.Line < b.Line
+	})
+
+	return tasks
+}
+
+// parseTaskRef reads the <memo>:<line> memo todo prints
+func parseTaskRef(ref string) (memoFile, int, error) {
+	name, lineText, ok := strings.Cut(ref, ":")
+	line, err := strconv.Atoi(lineText)
+	if !ok || err != nil || line < 1 {
+		return memoFile{}, 0, fmt.Errorf("[%s]: Tasks are named <memo>:<line>, like 3:12", ref)
+	}
+
+	filename := matchMemo(name)
+	if filename == "" {
+		return memoFile{}, 0, fmt.Errorf("[%s]: Couldn't match any memo", name)
+	}
+	for _, memo := range readMemos(getKeyValue("MemoDir").(string)) {
+		if memo.Path == filename {
+			return memo, line, nil
+		}
+	}
+
+	return memoFile{}, 0, fmt.Errorf("[%s]: Couldn't match any memo", name)
+}
+
+// toggleTask ticks the task on line of memo off, or opens it again,
+// and commits the memo
+func toggleTask(memo memoFile, line int) (memoTask, error) {
+	content, err := os.ReadFile(memo.Path)
+	if err != nil {
+		return memoTask{}, err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if line > len(lines) {
+		return memoTask{}, fmt.Errorf("memo %d has only %d lines", memo.Number, len(lines))
+	}
+	var task memoTask
+	for _, found := range readTasks(memo, content) {
+		if found.Line == line {
+			task = found
+		}
+	}
+	if task.Line == 0 {
+		return memoTask{}, fmt.Errorf("line %d of memo %d isn't a task", line, memo.Number)
+	}
+
+	task.Done = !task.Done
+	mark := " "
+	if task.Done {
+		mark = "x"
+	}
+	lines[line-1] = taskLine.ReplaceAllString(lines[line-1], "${1}"+mark+"${3}${4}")
+
+	if err := os.WriteFile(memo.Path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return memoTask{}, err
+	}
+
+	state := "Done"
+	if !task.Done {
+		state = "Reopen"
+	}
+	if err := commit(fmt.Sprintf("[Todo]: %s %q in %s", state, task.Text, memoTitle(memo.Path)), memo.Path); err != nil {
+		return memoTask{}, err
+	}
+
+	return task, nil
+}
+
+func printTasks(tasks []memoTask) {
+	refStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+	memoStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	lateStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87"))
+	soonStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFAF00"))
+	highStyle := lipgloss.NewStyle().Bold(true)
+
+	year, month, day := time.Now().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	for _, task := range tasks {
+		box := "[ ]"
+		if task.Done {
+			box = "[x]"
+		}
+
+		var meta []string
+		if !task.Due.IsZero() {
+			due := "due " + task.Due.Format(time.DateOnly)
+			switch days := int(math.Round(task.Due.Sub(today).Hours() / 24)); {
+			case task.Done:
+			case days < 0:
+				due = lateStyle.Render(due + " (overdue)")
+			case days == 0:
+				due = soonStyle.Render(due + " (today)")
+			case days <= 7:
+				due = soonStyle.Render(fmt.Sprintf("%s (in %d days)", due, days))
+			}
+			meta = append(meta, due)
+		}
+		if task.Priority > 0 {
+			priority := priorityNames[task.Priority]
+			if task.Priority == 1 {
+				priority = highStyle.Render(priority)
+			}
+			meta = append(meta, priority)
+		}
+		meta = append(meta, memoStyle.Render(strings.TrimSpace(getFileTitle(task.Memo.Path))))
+
+		ref := fmt.Sprintf("%d:%d", task.Memo.Number, task.Line)
+		fmt.Printf("%s %s %s  %s\n", refStyle.Render(fmt.Sprintf("%8s", ref)), box, task.Text, strings.Join(meta, "  "))
+	}
+}
+
+// taskBox is the checkbox of a task in the web UI, Line is where the
+// task is in the memo and 0 when that isn't known
+type taskBox struct {
+	ast.Leaf
+	Done bool
+	Line int
+}
+
+// taskMark carries the line of a task through the markdown renderer,
+// the characters are private use ones nobody types
+var taskMark = regexp.MustCompile("\uE000(\\d+)\uE001")
+
+// markTaskLines puts the line of every task of content next to its box,
+// markTasks takes them out again
+func markTaskLines(content []byte) []byte {
+	lines := strings.Split(string(content), "\n")
+	for _, task := range readTasks(memoFile{}, content) {
+		lines[task.Line-1] = taskLine.ReplaceAllString(lines[task.Line-1], fmt.Sprintf("${1}${2}${3}\uE000%d\uE001${4}", task.Line))
+	}
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// markTasks puts a checkbox in front of the list items starting with
+// [ ] or [x], and drops the marks of markTaskLines left in code blocks
+// and the like
+func markTasks(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		if item, ok := node.(*ast.ListItem); ok {
+			markTask(item)
+		}
+		if leaf := node.AsLeaf(); leaf != nil {
+			leaf.Literal = taskMark.ReplaceAll(leaf.Literal, nil)
+		}
+		if container := node.AsContainer(); container != nil {
+			container.Literal = taskMark.ReplaceAll(container.Literal, nil)
+		}
+
+		return ast.GoToNext
+	})
+}
+
+func markTask(item *ast.ListItem) {
+	if len(item.Children) == 0 {
+		return
+	}
+	para, ok := item.Children[0].(*ast.Paragraph)
+	if !ok || len(para.Children) == 0 {
+		return
+	}
+	text, ok := para.Children[0].(*ast.Text)
+	if !ok || len(text.Literal) < 4 {
+		return
+	}
+
+	prefix := strings.ToLower(string(text.Literal[:4]))
+	if prefix != "[ ] " && prefix != "[x] " {
+		return
+	}
+	box := &taskBox{Done: prefix == "[x] "}
+	rest := text.Literal[4:]
+	if m := taskMark.FindSubmatchIndex(rest); m != nil && len(bytes.TrimSpace(rest[:m[0]])) == 0 {
+		box.Line, _ = strconv.Atoi(string(rest[m[2]:m[3]]))
+		rest = rest[m[1]:]
+	}
+	text.Literal = rest
+	box.SetParent(para)
+	para.SetChildren(append([]ast.Node{box}, para.Children...))
+}
+
+// taskBoxLines are the lines of the tasks the memo page has a checkbox
+// for, a line in a code block looks like a task to readTasks only
+func taskBoxLines(memo memoFile, content []byte) map[int]bool {
+	doc := markdownParser().Parse(memoMarkdown(memo.Path, markTaskLines(content)))
+	markTasks(doc)
+
+	lines := map[int]bool{}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if box, ok := node.(*taskBox); ok && entering && box.Line > 0 {
+			lines[box.Line] = true
+		}
+		return ast.GoToNext
+	})
+
+	return lines
+}
+
+// renderTaskBox is a render hook writing the checkboxes of markTasks,
+// the script turns the ones with a line on when the memo can be changed
+func renderTaskBox(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	box, ok := node.(*taskBox)
+	if !ok {
+		return ast.GoToNext, false
+	}
+
+	attrs := ""
+	if box.Line > 0 {
+		attrs = fmt.Sprintf(" data-line=\"%d\"", box.Line)
+	}
+	if box.Done {
+		attrs += " checked"
+	}
+	fmt.Fprintf(w, "<input type=\"checkbox\" class=\"task\"%s disabled> ", attrs)
+
+	return ast.GoToNext, true
+}
+
+// taskForm sends the ticked checkboxes of a memo to /todo/<number>
+func taskForm(memo memoFile, content []byte) string {
+	if len(readTasks(memo, content)) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(`<form class="task-form" method="post" action="/todo/%d">
+<input type="hidden" name="version" value="%s">
+<input type="hidden" name="line">
+</form>`, memo.Number, contentVersion(content))
+}
+
+// toggleTaskPage ticks off the task posted to /todo/<number>
+func toggleTaskPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	memoWrites.Lock()
+	defer memoWrites.Unlock()
+
+	memo, _, ok := memoFromPath(r.URL.Path, "/todo/")
+	if !ok {
+		displayCustom404(w, r)
+		return
+	}
+	content, err := os.ReadFile(memo.Path)
+	if err != nil {
+		displayCustom404(w, r)
+		return
+	}
+	if r.PostFormValue("version") != contentVersion(content) {
+		http.Error(w, "This memo changed on disk, reload it and try again", http.StatusConflict)
+		return
+	}
+
+	line, _ := strconv.Atoi(r.PostFormValue("line"))
+	if !taskBoxLines(memo, content)[line] {
+		http.Error(w, "No such task", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := toggleTask(memo, line); err != nil {
+		log.Print(err)
+		http.Error(w, "Couldn't change the task", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, memoURL(memo), http.StatusSeeOther)
+}
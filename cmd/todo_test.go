@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useConfig points memo at a config of its own for the test, the memo
+// directory in it is returned
+func useConfig(t *testing.T, settings string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	memoDir := filepath.Join(dir, "memo")
+	if err := os.MkdirAll(memoDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(config, []byte("memodir = \""+memoDir+"\"\n"+settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GMEMOCONF", config)
+
+	return memoDir
+}
+
+func TestParseTask(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want memoTask
+	}{
+		{"- [ ] Buy milk", true, memoTask{Text: "Buy milk"}},
+		{"* [x] Done it", true, memoTask{Done: true, Text: "Done it"}},
+		{"  + [X] Nested", true, memoTask{Done: true, Text: "Nested"}},
+		{"1. [ ] Numbered", true, memoTask{Text: "Numbered"}},
+		{"> - [ ] Quoted", true, memoTask{Text: "Quoted"}},
+		{"- [ ] Renew @due(2026-11-01) @priority(high)", true,
+			memoTask{Text: "Renew", Due: time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local), Priority: 1}},
+		{"- [ ] Later @priority(3)", true, memoTask{Text: "Later", Priority: 3}},
+		{"- [ ] Bad date @due(2026-13-01)", true, memoTask{Text: "Bad date"}},
+		{"- [] Not a task", false, memoTask{}},
+		{"- [ ]No space", false, memoTask{}},
+		{"[ ] No list", false, memoTask{}},
+		{"- [y] Other mark", false, memoTask{}},
+	}
+
+	for _, test := range tests {
+		got, ok := parseTask(test.line)
+		if ok != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseTask(%q) = %+v, %v, want %+v, %v", test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+// The example of the review: tasks in the front matter and in code
+// blocks aren't tasks, and the lines count from the top of the file
+const taskMemo = `---
+title: Tasks
+notes:
+  - [ ] not a task
+---
+# Tasks
+
+- [ ] First
+- [x] Second
+
+` + "```md" + `
+- [ ] In a code block
+` + "```" + `
+
+~~~~
+- [ ] In a tilde block
+~~~
+- [ ] Still in it
+~~~~
+
+> - [ ] Quoted
+1. [ ] Numbered
+`
+
+func TestReadTasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		lines   []int
+	}{
+		{"memo", taskMemo, []int{8, 9, 21, 22}},
+		{"no tasks", "# Nothing\n\nto do\n", nil},
+		{"front matter only", "---\ntitle: x\n---\n- [ ] One\n", []int{4}},
+		{"not closed front matter", "---\n- [ ] One\n", []int{2}},
+		{"windows lines", "---\r\ntitle: x\r\n---\r\n- [ ] One\r\n", []int{4}},
+		{"unclosed fence", "- [ ] One\n```\n- [ ] Two\n", []int{1}},
+	}
+
+	for _, test := range tests {
+		var lines []int
+		for _, task := range readTasks(memoFile{}, []byte(test.content)) {
+			lines = append(lines, task.Line)
+		}
+		if !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("%s: readTasks finds lines %v, want %v", test.name, lines, test.lines)
+		}
+	}
+}
+
+func TestTaskBoxLines(t *testing.T) {
+	useConfig(t, "")
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[int]bool
+	}{
+		{"memo", taskMemo, map[int]bool{8: true, 9: true, 21: true, 22: true}},
+		// The line in the code block has no box to tick
+		{"code block only", "```\n- [ ] x\n```\n", map[int]bool{}},
+		{"indented code", "Text\n\n    - [ ] x\n", map[int]bool{}},
+	}
+
+	for _, test := range tests {
+		got := taskBoxLines(memoFile{Path: "memo.md"}, []byte(test.content))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: taskBoxLines = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMarkTasksHTML(t *testing.T) {
+	useConfig(t, "")
+
+	html := string(mdToHTML(memoMarkdown("memo.md", markTaskLines([]byte(taskMemo)))))
+
+	for _, want := range []string{
+		`<input type="checkbox" class="task" data-line="8" disabled=""> First`,
+		`<input type="checkbox" class="task" data-line="9" checked="" disabled=""> Second`,
+		`data-line="21" disabled=""> Quoted`,
+		`data-line="22" disabled=""> Numbered`,
+		"In a code block",
+		"Still in it",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("the page has no %q:\n%s", want, html)
+		}
+	}
+	if strings.ContainsAny(html, "\uE000\uE001") {
+		t.Errorf("the marks of markTaskLines are left in the page:\n%s", html)
+	}
+	if n := strings.Count(html, `class="task"`); n != 4 {
+		t.Errorf("the page has %d checkboxes, want 4", n)
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage who can log in to memo serve",
+	Long:  `Add users with a password or an API token, memo serve asks for them once there is at least one`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listUsers()
+	},
+}
+
+var userAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a user, asking for the password",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, _ := cmd.Flags().GetString("role")
+		if role != roleRead && role != roleWrite {
+			log.Fatalf("The role is either %s or %s", roleRead, roleWrite)
+		}
+
+		hash, err := askPassword(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		updateUsers(func(users []User) []User {
+			if _, ok := userIndex(users, args[0]); ok {
+				log.Fatalf("There already is a user named %q", args[0])
+			}
+			return append(users, User{Name: args[0], Password: hash, Role: role})
+		})
+
+		fmt.Printf("Added %s who can %s memos\n", args[0], role)
+	},
+}
+
+var userPasswdCmd = &cobra.Command{
+	Use:   "passwd <name>",
+	Short: "Change the password of a user",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		hash, err := askPassword(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		updateUsers(func(users []User) []User {
+			i, ok := userIndex(users, args[0])
+			if !ok {
+				log.Fatalf("There is no user named %q", args[0])
+			}
+			users[i].Password = hash
+			return users
+		})
+
+		fmt.Printf("Changed the password of %s\n", args[0])
+	},
+}
+
+var userTokenCmd = &cobra.Command{
+	Use:   "token <name>",
+	Short: "Create an API token for a user, replacing the old one",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := randomToken()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		updateUsers(func(users []User) []User {
+			i, ok := userIndex(users, args[0])
+			if !ok {
+				log.Fatalf("There is no user named %q", args[0])
+			}
+			users[i].TokenHash = hashToken(token)
+			return users
+		})
+
+		fmt.Printf("Token of %s, it isn't shown again:\n\n%s\n\nSend it as \"Authorization: Bearer <token>\"\n", args[0], token)
+	},
+}
+
+var userListCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List the users",
+	Run: func(cmd *cobra.Command, args []string) {
+		listUsers()
+	},
+}
+
+var userRemoveCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove"},
+	Short:   "Remove a user",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateUsers(func(users []User) []User {
+			i, ok := userIndex(users, args[0])
+			if !ok {
+				log.Fatalf("There is no user named %q", args[0])
+			}
+			return append(users[:i], users[i+1:]...)
+		})
+
+		fmt.Printf("Removed %s\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userAddCmd, userPasswdCmd, userTokenCmd, userListCmd, userRemoveCmd)
+
+	userAddCmd.Flags().String("role", roleRead, "What the user can do, "+roleRead+" or "+roleWrite)
+	for _, cmd := range []*cobra.Command{userAddCmd, userPasswdCmd} {
+		cmd.Flags().Bool("password-stdin", false, "Read the password from the standard input")
+	}
+}
+
+func userIndex(users []User, name string) (int, bool) {
+	for i, user := range users {
+		if user.Name == name {
+			return i, true
+		}
+	}
+
+	return -1, false
+}
+
+// updateUsers saves the users returned by change in the config
+func updateUsers(change func(users []User) []User) {
+	configFile := getKeyValue("configFile").(string)
+	conf := readConfig(configFile)
+
+	conf.Users = change(conf.Users)
+	if err := saveConfigToFile(configFile, conf); err != nil {
+		log.Fatalf("Couldn't save the config: %v", err)
+	}
+
+	// The hashes are still nobody else's business
+	if err := os.Chmod(configFile, 0600); err != nil {
+		log.Printf("Couldn't make %s private: %v", configFile, err)
+	}
+}
+
+// askPassword reads a new password and returns its bcrypt hash
+func askPassword(cmd *cobra.Command) (string, error) {
+	var password string
+
+	if fromStdin, _ := cmd.Flags().GetBool("password-stdin"); fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("couldn't read the password: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	} else {
+		var again string
+		err := huh.NewForm(huh.NewGroup(
+			huh.NewInput().Title("Password: ").Password(true).Value(&password),
+			huh.NewInput().Title("Once more: ").Password(true).Value(&again),
+		)).Run()
+		if err != nil {
+			return "", err
+		}
+		if password != again {
+			return "", errors.New("the passwords don't match")
+		}
+	}
+
+	if password == "" {
+		return "", errors.New("the password can't be empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func listUsers() {
+	users := readConfig(getKeyValue("configFile").(string)).Users
+	if len(users) == 0 {
+		fmt.Println("No users, anybody who can reach memo serve can read and change the memos")
+		return
+	}
+
+	nameStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4"))
+	infoStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	for _, user := range users {
+		info := "can " + user.Role
+		if user.TokenHash != "" {
+			info += ", has a token"
+		}
+		fmt.Printf("%s %s\n", nameStyle.Render(user.Name), infoStyle.Render(info))
+	}
+}
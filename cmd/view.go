@@ -7,36 +7,52 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 )
 
 // viewCmd represents the view command
 var viewCmd = &cobra.Command{
-	Use:   "view",
+	Use:   "view <memo>[#section]",
 	Short: "View Your Memo",
-	Long:  `View Your Memo`,
+	Long: `View Your Memo
+
+A #section after the memo shows only that part of it, the section is
+named by its heading or the anchor --toc lists.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		argsPassed := len(args)
 		if argsPassed > 0 {
-			numberView, err := strconv.Atoi(args[0])
-			if err != nil {
-				log.Fatalf("Couldn't get the filename, %v", err)
+			toc, _ := cmd.Flags().GetBool("toc")
+
+			filename := matchMemo(args[0])
+			section := ""
+			if filename == "" {
+				if memo, name, ok := strings.Cut(args[0], "#"); ok {
+					filename, section = matchMemo(memo), name
+				}
+			}
+			if filename == "" {
+				log.Fatalf("[%s]: Couldn't match any memo", args[0])
 			}
 
-			filename := matchMemoNumber(numberView)
-			displayMemo(filename)
+			displayMemo(filename, section, toc)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(viewCmd)
+	viewCmd.Flags().Bool("toc", false, "Show the table of contents before the memo")
 }
 
-func displayMemo(filename string) {
+// displayMemo prints a memo, or only section of it when not empty, with
+// its table of contents when toc is set
+func displayMemo(filename string, section string, toc bool) {
 	termSize := CalcTermSize()
 	if termSize > 80 {
 		termSize = termSize - 10
@@ -46,13 +62,151 @@ func displayMemo(filename string) {
 		log.Fatalf("Couldn't read the file, %v", err)
 	}
 
-	strCont := string(binCont)
+	// Plain text is already as readable as it gets in a terminal
+	if formatOf(filename).Name == "text" {
+		if section != "" {
+			log.Fatalf("[%s]: Plain text memos have no sections", section)
+		}
+		fmt.Println(lipgloss.NewStyle().Width(termSize).Render(string(binCont)))
+		printAttachments(filename)
+		return
+	}
+
+	md := memoMarkdown(filename, binCont)
+	if section != "" {
+		part, ok := memoSection(md, section)
+		if !ok {
+			log.Fatalf("[%s]: Couldn't find the section, --toc lists them", section)
+		}
+		md = []byte(part)
+	}
+	strCont := terminalMarkdown(string(md))
+	if toc {
+		strCont = tocMarkdown(md) + strCont
+	}
 
 	re, _ := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
+		themeOption(),
 		glamour.WithWordWrap(termSize),
 	)
 
 	disp, err := re.Render(strCont)
 	fmt.Print(disp)
+
+	if section == "" {
+		printAttachments(filename)
+	}
+}
+
+func printAttachments(filename string) {
+	attachments := listAttachments(filename)
+	if len(attachments) == 0 {
+		return
+	}
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4")).PaddingLeft(2)
+	pathStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	fmt.Println(titleStyle.Render("Attachments:"))
+	for _, name := range attachments {
+		path := filepath.Join(assetsDir(filename), name)
+		size := ""
+		if info, err := os.Stat(path); err == nil {
+			size = fmt.Sprintf(" (%d bytes)", info.Size())
+		}
+		fmt.Printf("    %s%s %s\n", name, size, pathStyle.Render(path))
+	}
+}
+
+// themeOption picks the glamour style from the Theme setting, it can
+// be one of the builtin styles (dark, light, dracula...) or a path to
+// a JSON style file.
+func themeOption() glamour.TermRendererOption {
+	theme := getKeyValue("Theme").(string)
+	if theme == "" || theme == "auto" {
+		return glamour.WithAutoStyle()
+	}
+
+	return glamour.WithStylePath(theme)
+}
+
+// Inline formulas, $...$ not inside a code span
+var inlineFormula = regexp.MustCompile(`\$([^$\n]+)\$`)
+
+// terminalMarkdown swaps formulas and diagrams for text glamour can
+// show, code blocks are left alone
+func terminalMarkdown(md string) string {
+	var out, block strings.Builder
+	fence, kind := "", ""
+	inFormula := false
+
+	for _, line := range strings.SplitAfter(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				if kind != "" {
+					fmt.Fprintf(&out, "*Diagram (%s), memo serve draws it:*\n\n```\n%s```\n", kind, diagramText(kind, block.String()))
+				} else {
+					out.WriteString(line)
+				}
+				fence, kind = "", ""
+				block.Reset()
+				continue
+			}
+			if kind != "" {
+				block.WriteString(line)
+			} else {
+				out.WriteString(line)
+			}
+		case inFormula || strings.HasPrefix(trimmed, "$$"):
+			block.WriteString(line)
+			inFormula = !strings.HasSuffix(trimmed, "$$") || (!inFormula && trimmed == "$$")
+			if !inFormula {
+				tex := strings.TrimSpace(block.String())
+				tex = strings.TrimSuffix(strings.TrimPrefix(tex, "$$"), "$$")
+				fmt.Fprintf(&out, "```\n%s\n```\n", mathText(tex))
+				block.Reset()
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			for len(fence) < len(trimmed) && trimmed[len(fence)] == fence[0] {
+				fence += fence[:1]
+			}
+			info := strings.Fields(trimmed[len(fence):])
+			if len(info) > 0 {
+				if _, ok := diagramTools[strings.ToLower(info[0])]; ok {
+					kind = strings.ToLower(info[0])
+					continue
+				}
+			}
+			out.WriteString(line)
+		default:
+			out.WriteString(inlineMath(line))
+		}
+	}
+	// A formula left open is shown as written
+	out.WriteString(block.String())
+
+	return out.String()
+}
+
+// inlineMath writes the formulas of a line as code spans of text,
+// skipping the code spans already there
+func inlineMath(line string) string {
+	parts := strings.Split(line, "`")
+	for i := 0; i < len(parts); i += 2 {
+		part := parts[i]
+		parts[i] = inlineFormula.ReplaceAllStringFunc(part, func(match string) string {
+			tex := match[1 : len(match)-1]
+			end := strings.Index(part, match) + len(match)
+			if !isFormula(tex, part[end:]) {
+				return match
+			}
+			return "`" + mathText(tex) + "`"
+		})
+	}
+
+	return strings.Join(parts, "`")
 }
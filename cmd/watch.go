@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often the directories are walked when they can't be watched
+const pollInterval = time.Second
+
+// Editors save in several steps, they are reported as one change
+const settleDelay = 200 * time.Millisecond
+
+// watchChanges calls changed whenever a file below dirs changes. It
+// uses inotify where it can and polls otherwise, it never returns so
+// run it in a goroutine of its own.
+func watchChanges(dirs []string, changed func()) {
+	changed = settle(changed, settleDelay)
+
+	if err := watchNotify(dirs, changed); err != nil {
+		log.Printf("Checking for changes every %v: %v", pollInterval, err)
+	}
+	watchPolling(dirs, changed)
+}
+
+// settle delays f until it hasn't been called for delay
+func settle(f func(), delay time.Duration) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(delay, f)
+	}
+}
+
+// ignoredName tells apart the files editors and git write on the side
+// (.git, .memo.md.swp, memo.md~, vim's 4913) from the ones that matter.
+func ignoredName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || name == "4913"
+}
+
+// watchedDirs lists dirs and every directory below them worth watching
+func watchedDirs(dirs []string) []string {
+	var all []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != dir && ignoredName(d.Name()) {
+				return filepath.SkipDir
+			}
+			all = append(all, path)
+			return nil
+		})
+	}
+
+	return all
+}
+
+// watchPolling walks dirs every pollInterval and compares the names,
+// sizes and modification times of the files with the last walk.
+func watchPolling(dirs []string, changed func()) {
+	last := dirsFingerprint(dirs)
+	for range time.Tick(pollInterval) {
+		if current := dirsFingerprint(dirs); current != last {
+			last = current
+			changed()
+		}
+	}
+}
+
+func dirsFingerprint(dirs []string) uint64 {
+	h := fnv.New64a()
+	for _, dir := range watchedDirs(dirs) {
+		fmt.Fprintln(h, dir)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || ignoredName(entry.Name()) {
+				continue
+			}
+			if info, err := entry.Info(); err == nil {
+				fmt.Fprintln(h, entry.Name(), info.Size(), info.ModTime().UnixNano())
+			}
+		}
+	}
+
+	return h.Sum64()
+}
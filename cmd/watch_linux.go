@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"log"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MODIFY | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// watchNotify watches dirs with inotify, it only returns when that
+// isn't possible (too many directories for max_user_watches...).
+func watchNotify(dirs []string, changed func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	// inotify isn't recursive, every directory needs a watch of its own.
+	// Adding a watch again for the same directory is harmless.
+	addWatches := func() error {
+		for _, dir := range watchedDirs(dirs) {
+			if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := addWatches(); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		relevant, newDir := false, false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				relevant = true
+				continue
+			}
+			if ignoredName(name) {
+				continue
+			}
+			relevant = true
+			if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				newDir = true
+			}
+		}
+
+		if newDir {
+			if err := addWatches(); err != nil {
+				log.Printf("Couldn't watch the new directory: %v", err)
+			}
+		}
+		if relevant {
+			changed()
+		}
+	}
+}
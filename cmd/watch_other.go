@@ -0,0 +1,13 @@
+//go:build !linux
+
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import "errors"
+
+// watchNotify is only implemented with inotify, other systems poll
+func watchNotify(dirs []string, changed func()) error {
+	return errors.New("no file notifications on this system")
+}
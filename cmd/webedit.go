@@ -0,0 +1,305 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// addEditRoutes adds the pages of the web UI that change memos
+func addEditRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/edit/", editPage)
+	mux.HandleFunc("/new", newPage)
+	mux.HandleFunc("/delete/", deletePage)
+	mux.HandleFunc("/preview", previewMemo)
+	mux.HandleFunc("/todo/", toggleTaskPage)
+}
+
+// contentVersion identifies what a memo looked like when the edit page
+// was loaded, saving is refused when it no longer matches the file.
+func contentVersion(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// sameOrigin refuses form posts coming from other sites, a page
+// elsewhere could otherwise delete memos through the browser. Browsers
+// send an Origin with every post, one without Origin or Referer is
+// refused as well.
+func sameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return false
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+
+	return u.Host == r.Host
+}
+
+// formContent is the textarea of a form, browsers send it with \r\n
+func formContent(r *http.Request) string {
+	return strings.ReplaceAll(r.PostFormValue("content"), "\r\n", "\n")
+}
+
+// editPage shows the editor of /edit/<number> and saves it on POST
+func editPage(w http.ResponseWriter, r *http.Request) {
+	memo, _, ok := memoFromPath(r.URL.Path, "/edit/")
+	if !ok {
+		displayCustom404(w, r)
+		return
+	}
+
+	// The version is checked against the file as it is when saving
+	if r.Method == http.MethodPost {
+		memoWrites.Lock()
+		defer memoWrites.Unlock()
+	}
+
+	current, err := os.ReadFile(memo.Path)
+	if err != nil {
+		displayCustom404(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		renderEditor(w, r, memo, string(current), contentVersion(current), "")
+	case http.MethodPost:
+		if !sameOrigin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		content := formContent(r)
+		if r.PostFormValue("version") != contentVersion(current) {
+			// Keep what was typed, the next save overwrites the file
+			w.WriteHeader(http.StatusConflict)
+			note := fmt.Sprintf("This memo changed on disk after you opened it. <a href=\"%s\" target=\"_blank\">See the current version</a>, saving again replaces it with the text below.", memoURL(memo))
+			renderEditor(w, r, memo, content, contentVersion(current), note)
+			return
+		}
+
+		if err := saveMemo(memo.Path, content); err != nil {
+			log.Print(err)
+			http.Error(w, "Couldn't save the memo", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, memoURL(memo), http.StatusSeeOther)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func renderEditor(w http.ResponseWriter, r *http.Request, memo memoFile, content string, version string, note string) {
+	form := fmt.Sprintf(`<form class="memo-editor" method="post" action="/edit/%d">
+<input type="hidden" name="version" value="%s">
+<input type="hidden" name="id" value="%d">
+%s
+<div class="editor-panes">
+<textarea name="content" spellcheck="true" autofocus>
+%s</textarea>
+<div class="editor-preview"></div>
+</div>
+<div class="editor-buttons"><button>Save</button> <a href="%s">Cancel</a></div>
+</form>`,
+		memo.Number, version, memo.Number, editorNote(note), template.HTMLEscapeString(content), memoURL(memo))
+
+	renderPage(w, r, inputData{
+		Title:       "Edit " + memoTitle(memo.Path),
+		Breadcrumbs: breadcrumbs(memo.Folder),
+		Main:        template.HTML(form),
+	})
+}
+
+func editorNote(note string) string {
+	if note == "" {
+		return ""
+	}
+
+	return "<p class=\"info_note\">" + note + "</p>"
+}
+
+// newPage asks for the title, folder and format of a new memo and
+// creates it on POST
+func newPage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		folder, _ := cleanFolder(r.URL.Query().Get("folder"))
+		renderNewForm(w, r, "", folder, getKeyValue("Format").(string), "", "")
+	case http.MethodPost:
+		if !sameOrigin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		title := strings.TrimSpace(r.PostFormValue("title"))
+		content := formContent(r)
+		folder, err := cleanFolder(r.PostFormValue("folder"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			renderNewForm(w, r, title, r.PostFormValue("folder"), r.PostFormValue("format"), content, err.Error())
+			return
+		}
+		format, err := findFormat(r.PostFormValue("format"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			renderNewForm(w, r, title, folder, "", content, err.Error())
+			return
+		}
+		if title == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			renderNewForm(w, r, title, folder, format.Name, content, "A memo needs a title")
+			return
+		}
+
+		memoWrites.Lock()
+		filename, err := saveNewMemo(title, folder, format, content)
+		memoWrites.Unlock()
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Couldn't create the memo", http.StatusInternalServerError)
+			return
+		}
+
+		memo, ok := memoByPath(filename)
+		if !ok {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, memoURL(memo), http.StatusSeeOther)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func renderNewForm(w http.ResponseWriter, r *http.Request, title string, folder string, formatName string, content string, note string) {
+	if format, err := findFormat(formatName); err == nil {
+		formatName = format.Name
+	}
+
+	var options string
+	for _, name := range formatNames() {
+		selected := ""
+		if name == formatName {
+			selected = " selected"
+		}
+		options += fmt.Sprintf("<option%s>%s</option>", selected, name)
+	}
+
+	form := fmt.Sprintf(`<form class="memo-editor" method="post" action="/new">
+%s
+<p><label>Title <input name="title" value="%s" required autofocus></label>
+<label>Folder <input name="folder" value="%s" placeholder="optional"></label>
+<label>Format <select name="format">%s</select></label></p>
+<div class="editor-panes">
+<textarea name="content" placeholder="Leave empty to start from the template">
+%s</textarea>
+<div class="editor-preview"></div>
+</div>
+<div class="editor-buttons"><button>Create</button> <a href="/">Cancel</a></div>
+</form>`,
+		editorNote(template.HTMLEscapeString(note)), template.HTMLEscapeString(title), template.HTMLEscapeString(folder), options, template.HTMLEscapeString(content))
+
+	renderPage(w, r, inputData{
+		Title:       "New memo",
+		Breadcrumbs: breadcrumbs(folder),
+		Main:        template.HTML(form),
+	})
+}
+
+// deletePage removes /delete/<number> on POST and goes back to its folder
+func deletePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	memoWrites.Lock()
+	defer memoWrites.Unlock()
+
+	memo, _, ok := memoFromPath(r.URL.Path, "/delete/")
+	if !ok {
+		displayCustom404(w, r)
+		return
+	}
+
+	if err := deleteMemo(memo.Path); err != nil {
+		log.Print(err)
+		http.Error(w, "Couldn't delete the memo", http.StatusInternalServerError)
+		return
+	}
+
+	back := "/"
+	if memo.Folder != "" && DirectoryExists(filepath.Join(getKeyValue("MemoDir").(string), memo.Folder)) {
+		back = "/folder/" + folderURL(memo.Folder)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// previewMemo renders the content posted by the editor the same way
+// the memo page would
+func previewMemo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	content := []byte(formContent(r))
+
+	var md []byte
+	if id, err := strconv.Atoi(r.PostFormValue("id")); err == nil {
+		memo, ok := memoByNumber(getKeyValue("MemoDir").(string), id)
+		if !ok {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		md = attachmentLinks(memoMarkdown(memo.Path, content), memo.Path, fmt.Sprintf("/attachments/%d/", memo.Number))
+	} else {
+		format, err := findFormat(r.PostFormValue("format"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		md = memoMarkdown("preview"+format.Extensions[0], content)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(mdToHTML(md))
+}
+
+// memoActions are the buttons under a memo on its page
+func memoActions(memo memoFile) string {
+	return fmt.Sprintf(`<div class="memo-actions">
+<a href="/edit/%d">Edit</a>
+<a href="/raw/%d">Raw</a>
+%s
+<form method="post" action="/delete/%d" data-confirm="Delete %s?"><button>Delete</button></form>
+</div>`, memo.Number, memo.Number, historyLink(memo), memo.Number, template.HTMLEscapeString(memoTitle(memo.Path)))
+}
@@ -0,0 +1,280 @@
+/*
+Copyright © 2024 Gekko Wrld
+*/
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Results shown on one page of /search
+const searchPageSize = 20
+
+// Matching lines shown under a result, and how much of each
+const (
+	snippetLines = 3
+	snippetWidth = 160
+)
+
+// searchHit is a memo on the search page with what the facets need
+type searchHit struct {
+	result searchResult
+	tags   []string
+	// yyyy-mm
+	month   string
+	excerpt string
+}
+
+// searchPage shows the memos matching ?q=, narrowed down by the tag
+// and month facets in ?tag= and ?month=. Without a query every memo
+// is browsed through the facets.
+func searchPage(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := strings.TrimSpace(params.Get("q"))
+	tag := strings.ToLower(params.Get("tag"))
+	month := params.Get("month")
+	page, err := strconv.Atoi(params.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	hits := searchHits(getKeyValue("MemoDir").(string), query)
+
+	// Every facet counts the memos left by the other one
+	tagCounts := map[string]int{}
+	monthCounts := map[string]int{}
+	var matched []searchHit
+	for _, hit := range hits {
+		hasTag := tag == "" || containsString(hit.tags, tag)
+		inMonth := month == "" || hit.month == month
+		if inMonth {
+			for _, t := range hit.tags {
+				tagCounts[t]++
+			}
+		}
+		if hasTag {
+			monthCounts[hit.month]++
+		}
+		if hasTag && inMonth {
+			matched = append(matched, hit)
+		}
+	}
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "<form class=\"search-form\" action=\"/search\"><input type=\"search\" name=\"q\" value=\"%s\" placeholder=\"Search memos\" autofocus>", template.HTMLEscapeString(query))
+	for _, kept := range []string{"tag", "month"} {
+		if value := params.Get(kept); value != "" {
+			fmt.Fprintf(&content, "<input type=\"hidden\" name=\"%s\" value=\"%s\">", kept, template.HTMLEscapeString(value))
+		}
+	}
+	content.WriteString(" <button>Search</button></form>")
+
+	content.WriteString("<div class=\"search-page\"><aside class=\"facets\">")
+	content.WriteString(facetList("Tags", "tag", tag, tagCounts, params, func(t string) string { return "#" + t }, false))
+	content.WriteString(facetList("Months", "month", month, monthCounts, params, func(m string) string { return m }, true))
+	content.WriteString("</aside><section class=\"search-results\">")
+
+	switch {
+	case len(matched) == 0 && query != "":
+		fmt.Fprintf(&content, "<p>No memo matches %s</p>", template.HTMLEscapeString(query))
+	case len(matched) == 0:
+		content.WriteString("<p>No memo matches these filters</p>")
+	case len(matched) == 1:
+		content.WriteString("<p class=\"result-count\">1 memo</p>")
+	default:
+		fmt.Fprintf(&content, "<p class=\"result-count\">%d memos</p>", len(matched))
+	}
+
+	highlight := queryRegex(query)
+	start := min((page-1)*searchPageSize, len(matched))
+	end := min(start+searchPageSize, len(matched))
+	for _, hit := range matched[start:end] {
+		memo := hit.result.Memo
+		fmt.Fprintf(&content, "<article class=\"search-result\"><a class=\"main-link\" href=\"%s\">%s</a> <span class=\"result-meta\">#%d · %s</span>",
+			memoURL(memo), highlightHTML(hit.result.Title, highlight, query != ""), memo.Number, hit.month)
+
+		if query == "" {
+			fmt.Fprintf(&content, "<p class=\"snippet\">%s</p>", template.HTMLEscapeString(hit.excerpt))
+		}
+		for i, line := range hit.result.Lines {
+			if i == snippetLines {
+				fmt.Fprintf(&content, "<p class=\"snippet more\">and %d more lines</p>", len(hit.result.Lines)-snippetLines)
+				break
+			}
+			fmt.Fprintf(&content, "<p class=\"snippet\"><span class=\"line-number\">%d</span> %s</p>", line.Number, highlightHTML(snippet(line.Text, highlight), highlight, true))
+		}
+		content.WriteString("</article>")
+	}
+
+	content.WriteString(pager("/search", page, len(matched), searchPageSize, params))
+	content.WriteString("</section></div>")
+
+	title := "Search"
+	if query != "" {
+		title = "Search: " + query
+	}
+	renderPage(w, r, inputData{Title: title, Main: template.HTML(content.String())})
+}
+
+// searchHits finds the memos matching query with their tags and month,
+// every memo when query is empty
+func searchHits(memoDir string, query string) []searchHit {
+	var results []searchResult
+	if query == "" {
+		for _, memo := range readMemos(memoDir) {
+			results = append(results, searchResult{Memo: memo, Title: strings.TrimSpace(memoTitle(memo.Path))})
+		}
+	} else {
+		results = searchMemos(memoDir, query)
+	}
+
+	var hits []searchHit
+	for _, result := range results {
+		content, err := os.ReadFile(result.Memo.Path)
+		if err != nil {
+			continue
+		}
+		fm, _ := splitFrontMatter(string(content))
+
+		hit := searchHit{result: result, tags: memoTags(string(content))}
+		if date := memoDate(result.Memo, fm); !date.IsZero() {
+			hit.month = date.Format("2006-01")
+		}
+		if query == "" {
+			hit.excerpt = memoExcerpt(string(memoMarkdown(result.Memo.Path, content)), snippetWidth)
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits
+}
+
+// snippet cuts text down to the part around the first match
+func snippet(text string, re *regexp.Regexp) string {
+	runes := []rune(text)
+	if len(runes) <= snippetWidth {
+		return text
+	}
+
+	start := 0
+	if loc := re.FindStringIndex(text); loc != nil {
+		start = max(len([]rune(text[:loc[0]]))-snippetWidth/4, 0)
+	}
+	end := min(start+snippetWidth, len(runes))
+
+	cut := string(runes[start:end])
+	if start > 0 {
+		cut = "…" + cut
+	}
+	if end < len(runes) {
+		cut += "…"
+	}
+
+	return cut
+}
+
+// highlightHTML escapes text and wraps what re matches in <mark>
+func highlightHTML(text string, re *regexp.Regexp, enabled bool) string {
+	if !enabled {
+		return template.HTMLEscapeString(text)
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		out.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		out.WriteString("<mark>" + template.HTMLEscapeString(text[loc[0]:loc[1]]) + "</mark>")
+		last = loc[1]
+	}
+	out.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return out.String()
+}
+
+// facetList links every value of a facet with its count, the selected
+// one links back to the search without it
+func facetList(heading string, param string, selected string, counts map[string]int, params url.Values, label func(string) string, newestFirst bool) string {
+	var values []string
+	for value, count := range counts {
+		if value != "" && count > 0 {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 && selected == "" {
+		return ""
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if newestFirst {
+			return values[i] > values[j]
+		}
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	list := fmt.Sprintf("<h2>%s</h2><ul>", heading)
+	if selected != "" {
+		list += fmt.Sprintf("<li class=\"selected\"><a href=\"%s\">%s ×</a></li>", searchURL(params, param, ""), template.HTMLEscapeString(label(selected)))
+	}
+	for _, value := range values {
+		if value == selected {
+			continue
+		}
+		list += fmt.Sprintf("<li><a href=\"%s\">%s</a> (%d)</li>", searchURL(params, param, value), template.HTMLEscapeString(label(value)), counts[value])
+	}
+
+	return list + "</ul>"
+}
+
+// searchURL is the current search with param set to value, back on the
+// first page
+func searchURL(params url.Values, param string, value string) string {
+	next := url.Values{}
+	for key, values := range params {
+		next[key] = values
+	}
+	next.Del("page")
+	if value == "" {
+		next.Del(param)
+	} else {
+		next.Set(param, value)
+	}
+
+	return "/search?" + next.Encode()
+}
+
+// pager links the pages of a listing at base with more than one
+func pager(base string, page int, total int, pageSize int, params url.Values) string {
+	pages := (total + pageSize - 1) / pageSize
+	if pages < 2 {
+		return ""
+	}
+
+	links := "<nav class=\"pager\">"
+	for p := 1; p <= pages; p++ {
+		if p == page {
+			links += fmt.Sprintf("<span class=\"current_page\"><a>%d</a></span> ", p)
+			continue
+		}
+		next := url.Values{}
+		for key, values := range params {
+			next[key] = values
+		}
+		next.Set("page", strconv.Itoa(p))
+		links += fmt.Sprintf("<a href=\"%s?%s\">%d</a> ", base, template.HTMLEscapeString(next.Encode()), p)
+	}
+
+	return links + "</nav>"
+}
@@ -2,9 +2,6 @@
 # -*- coding: utf-8 -*-
 
 UPSTREAM_REPO = "https://github.com/gekkowrld/memo"
-PRISM_CSS = "https://gist.github.com/gekkowrld/11622b8dd1adcb783d6fa23ed1883338/raw/7ebdac23526b21e4e30486357de9aed2aa84b815/prism.css"
-PRISM_JS = "https://gist.github.com/gekkowrld/11622b8dd1adcb783d6fa23ed1883338/raw/7ebdac23526b21e4e30486357de9aed2aa84b815/prism.js"
-PRISM_GIST = "https://gist.github.com/gekkowrld/11622b8dd1adcb783d6fa23ed1883338"
 
 
 def check_if_in_repo():
@@ -68,29 +65,6 @@ def install():
         print("Go is not installed. Please install it and try again.")
         sys.exit(1)
 
-    # Download the prism files (for language highlighting)
-    download_prism = False
-    try:
-        subprocess.run(["wget", "-c", PRISM_JS, "-O", "assets/prism.js"])
-        subprocess.run(["wget", "-c", PRISM_CSS, "-O", "assets/prism.css"])
-        download_prism = True
-    except FileNotFoundError:
-        pass
-
-    if not download_prism:
-        try:
-            # Use curl to download the files
-            subprocess.run(["curl", "-o", "assets/prism.js", PRISM_JS])
-            subprocess.run(["curl", "-o", "assets/prism.css", PRISM_CSS])
-            download_prism = True
-        except FileNotFoundError:
-            pass
-
-    if not download_prism:
-        print(
-            f"Couldn't download the files for language highlighting, find them here: {PRISM_GIST}\n"
-        )
-
     # Compile the code with no debug info and optimizations
     print("Building the go app with verbose and optimizations ON\n")
     subprocess.run([